@@ -1,7 +1,7 @@
 // Package buildlist is an API for gomodvet (a simple prototype of a potential future 'go mod vet' or similar).
 //
-// buildlist uses the context of the active module based on the current working directory to
-// allow examination of the build list.
+// buildlist uses the context of the active module based on a given directory (or the current
+// working directory) to allow examination of the build list.
 // See https://golang.org/cmd/go/#hdr-The_main_module_and_the_build_list for more on the build list.
 //
 // See the README at https://github.com/thepudds/gomodvet for more details on gomodvet.
@@ -9,13 +9,15 @@ package buildlist
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/thepudds/gomodvet/gotool"
 )
 
 // Module represents summary Go module information, as returned by 'go list -json -m all'.
@@ -45,31 +47,41 @@ type ModuleError struct {
 // providing packages to this build, including taking into account minimal version selection,
 // excludes, and replaces.
 // See https://golang.org/cmd/go/#hdr-The_main_module_and_the_build_list
-func Resolve() ([]Module, error) {
-	return resolve(false)
+// dir is the directory in which to run 'go list', with the empty string meaning the
+// current working directory.
+func Resolve(ctx context.Context, dir string) ([]Module, error) {
+	return ResolveWith(ctx, gotool.Exec{}, dir)
+}
+
+// ResolveWith is like Resolve, but runs the go command with t, such as to select
+// the go command and its environment (see gotool.Exec).
+func ResolveWith(ctx context.Context, t gotool.Tool, dir string) ([]Module, error) {
+	return resolve(ctx, t, dir, false)
 }
 
 // ResolveUpgrades returns the build list (including upgrades) in the form of []Module
 // as returned from 'go list -u -json -m all'.
 // See Resolve for details.
-func ResolveUpgrades() ([]Module, error) {
-	return resolve(true)
+func ResolveUpgrades(ctx context.Context, dir string) ([]Module, error) {
+	return ResolveUpgradesWith(ctx, gotool.Exec{}, dir)
 }
 
-func resolve(upgrades bool) ([]Module, error) {
-	var result []Module
-	var args []string
-	if upgrades {
-		args = []string{"list", "-mod=readonly", "-json", "-u", "-m", "all"}
-	} else {
-		args = []string{"list", "-mod=readonly", "-json", "-m", "all"}
-	}
-	out, err := exec.Command("go", args...).Output()
+// ResolveUpgradesWith is like ResolveUpgrades, but runs the go command with t.
+func ResolveUpgradesWith(ctx context.Context, t gotool.Tool, dir string) ([]Module, error) {
+	return resolve(ctx, t, dir, true)
+}
 
+func resolve(ctx context.Context, t gotool.Tool, dir string, upgrades bool) ([]Module, error) {
+	out, err := t.ListModules(ctx, dir, upgrades)
 	if err != nil {
-		return nil, fmt.Errorf("error invoking 'go list': %v", err)
+		return nil, err
 	}
+	return Parse(out)
+}
 
+// Parse parses the output of 'go list -json -m all' (with or without -u).
+func Parse(out []byte) ([]Module, error) {
+	var result []Module
 	dec := json.NewDecoder(bytes.NewReader(out))
 	for {
 		var m Module
@@ -84,19 +96,47 @@ func resolve(upgrades bool) ([]Module, error) {
 	return result, nil
 }
 
-// InModule reports if there appears to be a current 'go.mod'.
-func InModule() (bool, error) {
-	out, err := exec.Command("go", "env", "GOMOD").Output()
+// InModule reports if there appears to be a current 'go.mod' for dir,
+// with the empty string meaning the current working directory.
+func InModule(ctx context.Context, dir string) (bool, error) {
+	return InModuleWith(ctx, gotool.Exec{}, dir)
+}
+
+// InModuleWith is like InModule, but runs the go command with t.
+func InModuleWith(ctx context.Context, t gotool.Tool, dir string) (bool, error) {
+	path, err := GoModPathWith(ctx, t, dir)
 	if err != nil {
 		return false, err
 	}
+	return path != "", nil
+}
+
+// GoModPath returns the path to the current 'go.mod' for dir as reported by 'go env GOMOD',
+// or the empty string if there is no current 'go.mod'.
+// The empty string for dir means the current working directory.
+func GoModPath(ctx context.Context, dir string) (string, error) {
+	return GoModPathWith(ctx, gotool.Exec{}, dir)
+}
+
+// GoModPathWith is like GoModPath, but runs the go command with t.
+func GoModPathWith(ctx context.Context, t gotool.Tool, dir string) (string, error) {
+	out, err := t.Env(ctx, dir, "GOMOD")
+	if err != nil {
+		return "", err
+	}
+	return ParseGoModPath(out), nil
+}
+
+// ParseGoModPath parses the output of 'go env GOMOD', returning
+// the empty string if there is no current 'go.mod'.
+func ParseGoModPath(out []byte) string {
 	s := strings.TrimSpace(string(out))
 	if s == "" || s == os.DevNull {
 		// Go 1.11 reports empty string for no 'go.mod'.
 		// Go 1.12 beta (currently) reports os.DevNull for no 'go.mod'
-		return false, nil
+		return ""
 	}
-	return true, nil
+	return s
 }
 
 // ---------------------------------------------------
@@ -0,0 +1,124 @@
+package gotool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rogpeppe/go-internal/txtar"
+)
+
+// Fake is a Tool that returns canned output from a txtar archive rather than running
+// the go command, which allows rules to be tested without a real module setup or network access.
+// The archive holds one file for each command, named as follows:
+//
+//	env.txt                'go env', as KEY=VALUE lines
+//	list-m.json            'go list -json -m all'
+//	list-u-m.json          'go list -json -u -m all'
+//	packages.txt           'go list -mod=readonly -deps ./...', as "import/path name" lines
+//	graph.txt              'go mod graph'
+//
+// A command fails if the archive instead holds a file with the same base name
+// and a ".err" extension (such as "packages.err"), with the contents of
+// that file as the error message. Any other file, such as "go.mod", is a file
+// that can be read with ReadModFile. If env.txt does not set GOMOD, it is "go.mod".
+type Fake struct {
+	name  string
+	files map[string][]byte
+}
+
+// LoadFake returns a Fake backed by the txtar archive at path.
+func LoadFake(path string) (*Fake, error) {
+	a, err := txtar.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFake(path, a), nil
+}
+
+// NewFake returns a Fake backed by archive a. name is used in error messages.
+func NewFake(name string, a *txtar.Archive) *Fake {
+	f := &Fake{name: name, files: make(map[string][]byte)}
+	for _, file := range a.Files {
+		f.files[file.Name] = file.Data
+	}
+	return f
+}
+
+func (f *Fake) Env(ctx context.Context, dir string, keys ...string) ([]byte, error) {
+	var data []byte
+	if f.has("env.txt") {
+		var err error
+		if data, err = f.output("env.txt"); err != nil {
+			return nil, err
+		}
+	}
+	var out []byte
+	for _, key := range keys {
+		out = append(out, f.env(string(data), key)+"\n"...)
+	}
+	return out, nil
+}
+
+// env returns the value of key from the KEY=VALUE lines in data.
+func (f *Fake) env(data, key string) string {
+	for _, line := range strings.Split(data, "\n") {
+		if strings.HasPrefix(line, key+"=") {
+			return strings.TrimPrefix(line, key+"=")
+		}
+	}
+	if key == "GOMOD" {
+		return "go.mod"
+	}
+	return ""
+}
+
+func (f *Fake) ListModules(ctx context.Context, dir string, upgrades bool) ([]byte, error) {
+	if upgrades {
+		return f.output("list-u-m.json")
+	}
+	return f.output("list-m.json")
+}
+
+func (f *Fake) ListPackages(ctx context.Context, dir string) ([]byte, error) {
+	return f.output("packages.txt")
+}
+
+func (f *Fake) ModGraph(ctx context.Context, dir string) ([]byte, error) {
+	return f.output("graph.txt")
+}
+
+func (f *Fake) ReadModFile(ctx context.Context, path string) ([]byte, error) {
+	data, ok := f.files[filepath.ToSlash(path)]
+	if !ok {
+		return nil, fmt.Errorf("%s: no file %s: %w", f.name, path, os.ErrNotExist)
+	}
+	return data, nil
+}
+
+// has reports if the archive holds either name or its ".err" form.
+func (f *Fake) has(name string) bool {
+	_, ok := f.files[name]
+	_, failing := f.files[ErrName(name)]
+	return ok || failing
+}
+
+// output returns the canned output in the file name, or the canned error in its ".err" form.
+func (f *Fake) output(name string) ([]byte, error) {
+	if msg, ok := f.files[ErrName(name)]; ok {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(msg)))
+	}
+	data, ok := f.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: no canned output %s", f.name, name)
+	}
+	return data, nil
+}
+
+// ErrName returns the name of the file that makes the command with canned output in the file name fail,
+// such as "graph.err" for "graph.txt".
+func ErrName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".err"
+}
@@ -0,0 +1,197 @@
+// Package gotool is an API for gomodvet (a simple prototype of a potential future 'go mod vet' or similar).
+// gotool abstracts the invocations of the go command used by gomodvet, which allows
+// the go command to be replaced by canned output when testing.
+//
+// See the README at https://github.com/thepudds/gomodvet for more details.
+package gotool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/thepudds/gomodvet/trace"
+)
+
+// Tool runs the go command on behalf of gomodvet. Each method returns the unparsed output
+// of the corresponding go command, which callers then parse (for example, with buildlist.Parse).
+// dir is the directory in which to run the go command, with the empty string meaning
+// the current working directory.
+type Tool interface {
+	// Env returns the output of 'go env key...', which is the value of each key on its own line.
+	Env(ctx context.Context, dir string, keys ...string) ([]byte, error)
+
+	// ListModules returns the output of 'go list -json -m all',
+	// or 'go list -json -u -m all' if upgrades is true.
+	ListModules(ctx context.Context, dir string, upgrades bool) ([]byte, error)
+
+	// ListPackages returns the import path and name of each package in the main module, as
+	// "import/path name" lines from 'go list -mod=readonly -deps ./...'. The dependencies are
+	// loaded so that 'go list' fails if 'go.mod' or 'go.sum' would need an update, and their
+	// lines are blank.
+	ListPackages(ctx context.Context, dir string) ([]byte, error)
+
+	// ModGraph returns the output of 'go mod graph'.
+	ModGraph(ctx context.Context, dir string) ([]byte, error)
+
+	// ReadModFile returns the contents of the 'go.mod' file at path, such as a path
+	// reported by 'go env GOMOD' or by the GoMod field of 'go list -json -m all'.
+	// Callers parse the contents with modfile.ParseData, rather than running 'go mod edit -json'.
+	// ReadModFile can also read related files, such as 'go.sum' or 'go.work'.
+	// A missing file is reported by an error for which errors.Is(err, os.ErrNotExist) is true.
+	ReadModFile(ctx context.Context, path string) ([]byte, error)
+}
+
+// GoEnvVar is the environment variable that selects the go command used by Exec,
+// if Exec.Go is not set.
+const GoEnvVar = "GOMODVET_GO"
+
+// Exec is the default Tool, which runs the go command.
+// The zero value runs the go command named by $GOMODVET_GO, or else the go command found in PATH,
+// with the environment of the current process.
+type Exec struct {
+	// Go is the path to the go command, such as "/opt/go1.22/bin/go".
+	Go string
+
+	// Environ holds additional environment variables in the form "KEY=value", such as
+	// "GOFLAGS=-mod=mod" or "GOPROXY=off". These override the corresponding variables
+	// inherited from the current process, for every invocation of the go command.
+	Environ []string
+
+	// Offline prevents the go command from accessing the network, by running it with
+	// GOPROXY=off and with -mod=readonly added to GOFLAGS (so that a vendor directory is not used
+	// in place of the module cache, and 'go.mod' and 'go.sum' are never updated). Other GOFLAGS,
+	// such as -modcacherw, are kept.
+	// GOPROXY and a -mod flag in Environ take precedence. Module information that is not
+	// already in the module cache is then reported as a *NotCachedError.
+	Offline bool
+
+	// Trace, if not nil, records each invocation of the go command by Run.
+	Trace *trace.Trace
+}
+
+// offlineGOFLAGS returns GOFLAGS for an offline Exec with the given Environ: the GOFLAGS
+// from environ, or else inherited from the current process, with -mod=readonly in place of
+// any -mod flag. A -mod flag in environ is kept instead.
+func offlineGOFLAGS(environ []string) string {
+	flags, explicit := os.Getenv("GOFLAGS"), false
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, "GOFLAGS=") {
+			flags, explicit = strings.TrimPrefix(kv, "GOFLAGS="), true
+		}
+	}
+	var kept []string
+	for _, f := range strings.Fields(flags) {
+		if strings.HasPrefix(f, "-mod=") || strings.HasPrefix(f, "--mod=") {
+			if explicit {
+				return flags
+			}
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return strings.Join(append(kept, "-mod=readonly"), " ")
+}
+
+// NotCachedError is returned by an offline Exec if the go command needed module information,
+// such as the 'go.mod' file for a dependency, that is not in the module cache.
+type NotCachedError struct {
+	Err error
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf("%v\nmodule information is missing from the module cache, which is required when offline; run 'go mod download' while online", e.Err)
+}
+
+func (e Exec) Env(ctx context.Context, dir string, keys ...string) ([]byte, error) {
+	return e.Run(ctx, dir, append([]string{"env"}, keys...)...)
+}
+
+func (e Exec) ListModules(ctx context.Context, dir string, upgrades bool) ([]byte, error) {
+	if upgrades {
+		return e.Run(ctx, dir, "list", "-mod=readonly", "-json", "-u", "-m", "all")
+	}
+	return e.Run(ctx, dir, "list", "-mod=readonly", "-json", "-m", "all")
+}
+
+func (e Exec) ListPackages(ctx context.Context, dir string) ([]byte, error) {
+	return e.Run(ctx, dir, "list", "-mod=readonly", "-deps", "-f", "{{with .Module}}{{if .Main}}{{$.ImportPath}} {{$.Name}}{{end}}{{end}}", "./...")
+}
+
+func (e Exec) ModGraph(ctx context.Context, dir string) ([]byte, error) {
+	return e.Run(ctx, dir, "mod", "graph")
+}
+
+func (e Exec) ReadModFile(ctx context.Context, path string) ([]byte, error) {
+	return ioutil.ReadFile(path)
+}
+
+// Command returns an *exec.Cmd to run the go command with args in dir,
+// using e's go command and environment.
+func (e Exec) Command(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	gocmd := e.Go
+	if gocmd == "" {
+		gocmd = os.Getenv(GoEnvVar)
+	}
+	if gocmd == "" {
+		gocmd = "go"
+	}
+	cmd := exec.CommandContext(ctx, gocmd, args...)
+	cmd.Dir = dir
+	var env []string
+	if e.Offline {
+		env = append(env, "GOPROXY=off")
+	}
+	env = append(env, e.Environ...)
+	if e.Offline {
+		env = append(env, "GOFLAGS="+offlineGOFLAGS(e.Environ))
+	}
+	if len(env) > 0 {
+		// later entries take precedence.
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
+
+// Run runs the go command with args in dir, returning its stdout.
+// If the command fails, the error includes its stderr.
+func (e Exec) Run(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := e.Command(ctx, dir, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	start := time.Now()
+	out, err := cmd.Output()
+	if e.Trace != nil {
+		exitCode := -1
+		if cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+		}
+		e.Trace.Add(trace.Event{
+			Kind:     trace.KindGo,
+			Name:     "go " + strings.Join(args, " "),
+			Start:    start,
+			Duration: time.Since(start),
+			ExitCode: exitCode,
+			Bytes:    len(out) + stderr.Len(),
+		})
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			err = fmt.Errorf("error invoking 'go %s': %v: %s", strings.Join(args, " "), err, msg)
+		} else {
+			err = fmt.Errorf("error invoking 'go %s': %v", strings.Join(args, " "), err)
+		}
+		if e.Offline && strings.Contains(msg, "GOPROXY=off") {
+			// such as "module lookup disabled by GOPROXY=off".
+			err = &NotCachedError{Err: err}
+		}
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,28 @@
+package gotool
+
+import (
+	"os"
+	"testing"
+)
+
+func TestOfflineGOFLAGS(t *testing.T) {
+	defer os.Setenv("GOFLAGS", os.Getenv("GOFLAGS"))
+	tests := []struct {
+		inherited string
+		environ   []string
+		want      string
+	}{
+		{"", nil, "-mod=readonly"},
+		{"-modcacherw -tags=integration", nil, "-modcacherw -tags=integration -mod=readonly"},
+		{"-mod=vendor -modcacherw", nil, "-modcacherw -mod=readonly"},
+		{"-mod=mod", nil, "-mod=readonly"},
+		{"-modcacherw", []string{"GOFLAGS=-tags=integration"}, "-tags=integration -mod=readonly"},
+		{"-modcacherw", []string{"GOFLAGS=-mod=mod"}, "-mod=mod"},
+	}
+	for _, tt := range tests {
+		os.Setenv("GOFLAGS", tt.inherited)
+		if got := offlineGOFLAGS(tt.environ); got != tt.want {
+			t.Errorf("offlineGOFLAGS(%q) with GOFLAGS=%q = %q, want %q", tt.environ, tt.inherited, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/thepudds/gomodvet/report"
+	"github.com/thepudds/gomodvet/trace"
+	"github.com/thepudds/gomodvet/vet"
+)
+
+// graph implements 'gomodvet graph [-format=dot|mermaid|json] [-buildlist] [-highlight=module]',
+// writing the module requirement graph to stdout with nodes colored by the kind of version.
+func graph(args []string, tr *trace.Trace) int {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := fs.String("format", "dot", "output format: dot, mermaid, or json")
+	buildList := fs.Bool("buildlist", false, "collapse the graph to the versions selected in the build list")
+	highlight := fs.String("highlight", "", "highlight every chain of requirements from the main module to this module path")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gomodvet graph [-format=dot|mermaid|json] [-buildlist] [-highlight=module]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		// fs has already reported the error.
+		return ArgErr
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return ArgErr
+	}
+	if *flagJSON {
+		*format = "json"
+	}
+	write := map[string]func(*vet.ExportedGraph) error{
+		"dot":     func(g *vet.ExportedGraph) error { return report.DOT(os.Stdout, g) },
+		"mermaid": func(g *vet.ExportedGraph) error { return report.Mermaid(os.Stdout, g) },
+		"json":    func(g *vet.ExportedGraph) error { return report.GraphJSON(os.Stdout, g) },
+	}[*format]
+	if write == nil {
+		fmt.Fprintf(os.Stderr, "gomodvet graph: unknown format %q\n", *format)
+		fs.Usage()
+		return ArgErr
+	}
+
+	ctx := context.Background()
+	if *flagTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *flagTimeout)
+		defer cancel()
+	}
+	s, status := moduleSnapshot(ctx, tr)
+	if status != Success {
+		return status
+	}
+	g, err := vet.ExportGraph(ctx, s, vet.GraphOptions{BuildList: *buildList, Highlight: *highlight})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		if _, ok := err.(*vet.UsageError); ok {
+			return ArgErr
+		}
+		return InternalErr
+	}
+	if err := write(g); err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		return InternalErr
+	}
+	return Success
+}
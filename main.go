@@ -4,30 +4,76 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
-	"github.com/thepudds/gomodvet/buildlist"
+	"github.com/thepudds/gomodvet/gotool"
+	"github.com/thepudds/gomodvet/report"
+	"github.com/thepudds/gomodvet/trace"
 	"github.com/thepudds/gomodvet/vet"
 )
 
 var (
-	flagConflictingRequires = flag.Bool("conflictingrequires", true, "report if there are requirements for potentially conflicting v0 versions or '+incompatible' versions for different major versions")
-	flagExcludedVersion     = flag.Bool("excludedversion", true, "report if the current build is using a version excluded by a dependency")
-	flagMultipleMajor       = flag.Bool("multiplemajor", true, "report if a module has multiple major versions in use")
-	flagPrerelease          = flag.Bool("prerelease", true, "report if the current build is using a prerelease version (exclusive of pseudo-versions, which are reported separately)")
-	flagPseudoVersion       = flag.Bool("pseudoversion", true, "report if the current build is using a pseudo-version")
-	flagReplace             = flag.Bool("replace", true, "report if the main module is using any 'replace' directives")
-	flagUpgrades            = flag.Bool("upgrades", true, "report if the current module has available updates for its dependencies")
-	flagVerbose             = flag.Bool("v", false, "verbose: show additional information")
+	flagVerbose = flag.Bool("v", false, "verbose: show additional information")
+	flagJSON    = flag.Bool("json", false, "emit findings as a JSON document on stdout")
+	flagSARIF   = flag.Bool("sarif", false, "emit findings as a SARIF 2.1.0 log on stdout")
+	flagList    = flag.Bool("list", false, "list the available rules and exit")
+	flagConfig  = flag.String("config", "", "path to a configuration file (default '"+vet.ConfigFileName+"' next to the main module's 'go.mod', if present)")
+
+	flagBaseline      = flag.String("baseline", "", "path to a baseline file of accepted findings (default '"+vet.BaselineFileName+"' next to the main module's 'go.mod', if present)")
+	flagWriteBaseline = flag.Bool("writebaseline", false, "write the current findings to the baseline file and exit")
+	flagTimeout       = flag.Duration("timeout", 0, "stop and fail if vetting takes longer than this duration, such as '2m' (default no timeout)")
+	flagFailOn        = flag.String("fail-on", "info", "exit with a non-zero status for findings of this severity or higher (error, warning, or info)")
+
+	flagGo  = flag.String("go", "", "path to the go command to run (default $"+gotool.GoEnvVar+", or else 'go' from PATH)")
+	flagEnv envFlag
+
+	flagOffline  = flag.Bool("offline", false, "do not access the network, skipping rules that need it and using only the module cache")
+	flagParallel = flag.Int("p", 0, "the number of rules that can run in parallel (default GOMAXPROCS)")
+	flagNoCache  = flag.Bool("nocache", false, "do not use the on-disk cache of results for unchanged modules")
+	flagTrace    = flag.String("trace", "", "print the timing of each go command and rule to stderr as a table ('-trace=table'), or write it to a file in the Chrome trace format (such as '-trace=trace.json')")
+
+	// ruleFlags enables or disables each registered rule, keyed by rule ID.
+	// The flag names and help come from the rules themselves.
+	ruleFlags = defineRuleFlags()
 )
 
+func init() {
+	flag.Var(&flagEnv, "env", "set an environment variable for every go command run, such as '-env=GOPROXY=off' (may be repeated)")
+}
+
+// envFlag is a repeatable flag of KEY=value environment variables.
+type envFlag []string
+
+func (f *envFlag) String() string { return strings.Join(*f, " ") }
+
+func (f *envFlag) Set(s string) error {
+	if i := strings.Index(s, "="); i <= 0 {
+		return fmt.Errorf("must be of the form KEY=value")
+	}
+	*f = append(*f, s)
+	return nil
+}
+
+func defineRuleFlags() map[string]*bool {
+	flags := make(map[string]*bool)
+	for _, r := range vet.Rules() {
+		flags[r.ID()] = flag.Bool(r.Name(), r.DefaultEnabled(), r.Description())
+	}
+	return flags
+}
+
 // constants for status codes for os.Exit()
 const (
-	Success  = 0
-	OtherErr = 1
-	ArgErr   = 2
+	Success             = 0 // no findings at or above the -fail-on severity
+	FindingsErr         = 1 // findings at or above the -fail-on severity
+	ArgErr              = 2 // usage error, such as a bad flag or configuration file
+	InternalErr         = 3 // gomodvet or the go command failed
+	GoModNeedsUpdateErr = 4 // the current module's 'go.mod' needs to be updated, so other rules were not run
 )
 
 func main() {
@@ -40,57 +86,343 @@ func gomodvetMain() int {
 
 	flag.Parse()
 
-	status := Success
-	// check we have a current go.mod
-	modExists, err := buildlist.InModule()
+	if *flagList {
+		listRules()
+		return Success
+	}
+
+	var tr *trace.Trace
+	if *flagTrace != "" {
+		tr = trace.New()
+		// write the trace however we exit, given it is most useful for a slow or failing run.
+		defer writeTrace(tr, *flagTrace)
+	}
+
+	if flag.NArg() > 0 {
+		return subcommand(flag.Args(), tr)
+	}
+
+	if *flagJSON && *flagSARIF {
+		fmt.Fprintln(os.Stderr, "gomodvet: -json and -sarif are mutually exclusive")
+		return ArgErr
+	}
+	if *flagParallel < 0 {
+		fmt.Fprintln(os.Stderr, "gomodvet: -p must not be negative")
+		return ArgErr
+	}
+	failOn, err := vet.ParseSeverity(*flagFailOn)
 	if err != nil {
-		// TODO: stderr? stdout? For now, mostly stdout across the board.
-		fmt.Println("gomodvet:", err)
-		return OtherErr
+		fmt.Fprintln(os.Stderr, "gomodvet: -fail-on:", err)
+		return ArgErr
 	}
-	if !modExists {
-		fmt.Println("gomodvet: no current 'go.mod' file. please run from within a module with module-mode enabled.")
-		return OtherErr
+
+	// in JSON or SARIF mode, stdout is reserved for the JSON document, so diagnostics go to stderr.
+	machineOutput := *flagJSON || *flagSARIF
+	diag := os.Stdout
+	if machineOutput {
+		diag = os.Stderr
 	}
 
-	// gomodvet-001
-	updateNeeded, err := vet.GoModNeedsUpdate(*flagVerbose)
+	// load an explicitly requested config file here; otherwise vet.Run looks next to our 'go.mod'.
+	var config *vet.Config
+	if *flagConfig != "" {
+		config, err = vet.LoadConfig(*flagConfig)
+		if err != nil {
+			fmt.Fprintln(diag, "gomodvet:", err)
+			return ArgErr
+		}
+	}
+
+	ctx := context.Background()
+	if *flagTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *flagTimeout)
+		defer cancel()
+	}
+
+	var cache *vet.Cache
+	if !*flagNoCache {
+		// without a usable cache directory, we just run without the cache.
+		if dir, err := vet.DefaultCacheDir(); err == nil {
+			cache = &vet.Cache{Dir: dir}
+		}
+	}
+
+	// run our enabled rules, with gomodvet-001 first, and then the rest in parallel.
+	res, err := vet.Run(ctx, vet.Options{
+		Verbose:  *flagVerbose,
+		Out:      diag,
+		Tool:     gotool.Exec{Go: *flagGo, Environ: flagEnv, Offline: *flagOffline, Trace: tr},
+		Config:   config,
+		Enabled:  ruleFlagsSet(),
+		Offline:  *flagOffline,
+		Parallel: *flagParallel,
+		Cache:    cache,
+		Trace:    tr,
+	})
 	if err != nil {
-		fmt.Println("gomodvet:", err)
-		return OtherErr
-	}
-	if updateNeeded {
-		// we probably should not proceed in this case, so report, then return to end our processing.
-		fmt.Println("gomodvet: exiting prior to checking other rules.")
-		return OtherErr
-	}
-
-	// loop over our remaining vet checks
-	funcs := []struct {
-		flag    *bool
-		vetFunc func(bool) (bool, error)
-	}{
-		{flagUpgrades, vet.Upgrades},                       // gomodvet-002
-		{flagMultipleMajor, vet.MultipleMajor},             // gomodvet-003
-		{flagConflictingRequires, vet.ConflictingRequires}, // gomodvet-004
-		{flagExcludedVersion, vet.ExcludedVersion},         // gomodvet-005
-		{flagPrerelease, vet.Prerelease},                   // gomodvet-006
-		{flagPseudoVersion, vet.PseudoVersion},             // gomodvet-007
-		{flagReplace, vet.Replace},                         // gomodvet-008
-	}
-
-	for i := range funcs {
-		if *funcs[i].flag {
-			flagged, err := funcs[i].vetFunc(*flagVerbose)
-			if err != nil {
-				fmt.Println("gomodvet:", err)
-				return OtherErr
-			}
-			if flagged {
-				status = OtherErr
-			}
+		if ctx.Err() == context.DeadlineExceeded {
+			fmt.Fprintf(diag, "gomodvet: %v (-timeout=%v)\n", err, *flagTimeout)
+			return InternalErr
+		}
+		fmt.Fprintln(diag, "gomodvet:", err)
+		if _, ok := err.(*vet.UsageError); ok || err == vet.ErrNoModule {
+			return ArgErr
+		}
+		return InternalErr
+	}
+	goMod, findings := res.GoMod, res.Findings
+
+	var rules []report.Rule
+	for _, r := range vet.Rules() {
+		rules = append(rules, report.Rule{
+			ID:          r.ID(),
+			Name:        r.Name(),
+			Description: r.Description(),
+			Severity:    res.Config.Severity(r).String(),
+			Ran:         res.Ran[r.ID()],
+			Skipped:     res.Skipped[r.ID()],
+		})
+	}
+
+	baselinePath := *flagBaseline
+	if baselinePath == "" {
+		baselinePath = filepath.Join(filepath.Dir(goMod), vet.BaselineFileName)
+	}
+	if *flagWriteBaseline {
+		if res.Stopped() {
+			fmt.Fprintln(diag, "gomodvet: cannot write a baseline while the current module's 'go.mod' file needs to be updated.")
+			return GoModNeedsUpdateErr
+		}
+		baseline := vet.NewBaseline(findings)
+		if err := baseline.Write(baselinePath); err != nil {
+			fmt.Fprintln(diag, "gomodvet:", err)
+			return InternalErr
 		}
+		fmt.Fprintf(diag, "gomodvet: wrote %d findings to baseline file %s\n", len(baseline.Findings), baselinePath)
+		return Success
 	}
 
+	// compare against our baseline file, if any, so that only new findings are treated as problems.
+	var summary *report.Baseline
+	baseline, err := vet.LoadBaseline(baselinePath)
+	if err != nil {
+		fmt.Fprintln(diag, "gomodvet:", err)
+		return ArgErr
+	}
+	if baseline != nil {
+		var fixed []vet.BaselineEntry
+		findings, fixed = baseline.Apply(findings, res.Ran)
+		summary = &report.Baseline{File: baselinePath, Fixed: fixed}
+	}
+
+	if err := render(rules, findings, summary, goMod); err != nil {
+		fmt.Fprintln(diag, "gomodvet:", err)
+		return InternalErr
+	}
+	for _, r := range rules {
+		if r.Skipped != "" {
+			fmt.Fprintf(diag, "gomodvet: %s (%s): skipped: %s\n", r.ID, r.Name, r.Skipped)
+		}
+	}
+	if summary != nil {
+		for _, e := range summary.Fixed {
+			fmt.Fprintf(diag, "gomodvet: fixed, can be removed from %s: %s\n", summary.File, e)
+		}
+	}
+	if res.Stopped() {
+		// we probably should not proceed in this case, so report, then end our processing.
+		fmt.Fprintln(diag, "gomodvet: exiting prior to checking other rules.")
+	}
+	return exitStatus(findings, failOn)
+}
+
+// subcommand runs the gomodvet subcommand in args, such as 'gomodvet cache clean' or 'gomodvet why'.
+// tr, if not nil, records each invocation of the go command.
+func subcommand(args []string, tr *trace.Trace) int {
+	switch args[0] {
+	case "cache":
+		if len(args) != 2 || args[1] != "clean" {
+			fmt.Fprintln(os.Stderr, "usage: gomodvet cache clean")
+			return ArgErr
+		}
+		return cacheClean()
+	case "why":
+		return why(args[1:], tr)
+	case "graph":
+		return graph(args[1:], tr)
+	case "metrics":
+		return metrics(args[1:], tr)
+	}
+	fmt.Fprintf(os.Stderr, "gomodvet: unknown command %q\n", args[0])
+	return ArgErr
+}
+
+// moduleSnapshot returns a Snapshot for the current module, for use by subcommands.
+// If there is no current module or it cannot be determined, moduleSnapshot reports the error
+// and returns the exit status.
+func moduleSnapshot(ctx context.Context, tr *trace.Trace) (*vet.Snapshot, int) {
+	s := vet.NewSnapshot("", *flagVerbose)
+	s.Tool = gotool.Exec{Go: *flagGo, Environ: flagEnv, Offline: *flagOffline, Trace: tr}
+	// stdout is reserved for the output of the subcommand, such as a graph.
+	s.Out = os.Stderr
+	goMod, err := s.GoMod(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		return nil, InternalErr
+	}
+	if goMod == "" {
+		fmt.Fprintln(os.Stderr, "gomodvet:", vet.ErrNoModule)
+		return nil, ArgErr
+	}
+	return s, Success
+}
+
+// ruleEnabled reports if the rule r is enabled for a subcommand, in the same way as for vet.Run:
+// a rule flag on the command line takes precedence over the configuration file, which is
+// -config if set, or else the file next to the main module's 'go.mod'.
+// If the configuration file cannot be loaded, ruleEnabled reports the error and returns the exit status.
+func ruleEnabled(ctx context.Context, s *vet.Snapshot, r vet.Rule) (bool, int) {
+	if enabled, ok := ruleFlagsSet()[r.ID()]; ok {
+		return enabled, Success
+	}
+	path := *flagConfig
+	if path == "" {
+		goMod, err := s.GoMod(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gomodvet:", err)
+			return false, InternalErr
+		}
+		path = filepath.Join(filepath.Dir(goMod), vet.ConfigFileName)
+	}
+	config, err := vet.LoadConfig(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		return false, ArgErr
+	}
+	return config.Enabled(r), Success
+}
+
+// cacheClean implements 'gomodvet cache clean', removing the on-disk cache.
+func cacheClean() int {
+	dir, err := vet.DefaultCacheDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		return InternalErr
+	}
+	if err := (&vet.Cache{Dir: dir}).Clean(); err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		return InternalErr
+	}
+	if *flagVerbose {
+		fmt.Println("gomodvet: removed", dir)
+	}
+	return Success
+}
+
+// writeTrace writes tr to stderr as a table if dest is "table",
+// or else to the file dest in the Chrome trace format.
+func writeTrace(tr *trace.Trace, dest string) {
+	if dest == "table" {
+		tr.WriteTable(os.Stderr)
+		return
+	}
+	f, err := os.Create(dest)
+	if err == nil {
+		err = tr.WriteChrome(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet: -trace:", err)
+	}
+}
+
+// exitStatus returns the status code for findings, ignoring suppressed and baselined findings.
+// A gomodvet-001 finding takes precedence, given the other rules were not run.
+func exitStatus(findings []vet.Finding, failOn vet.Severity) int {
+	status := Success
+	for _, f := range findings {
+		if f.Suppression != nil || f.Baselined {
+			continue
+		}
+		if f.Rule == vet.GoModNeedsUpdateID {
+			return GoModNeedsUpdateErr
+		}
+		if f.Severity.AtLeast(failOn) {
+			status = FindingsErr
+		}
+	}
 	return status
 }
+
+// ruleFlagsSet reports which rules were explicitly enabled or disabled on the command line,
+// keyed by rule ID. These take precedence over the configuration file, which in turn
+// takes precedence over each rule's default.
+func ruleFlagsSet() map[string]bool {
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	enabled := make(map[string]bool)
+	for _, r := range vet.Rules() {
+		if setFlags[r.Name()] {
+			enabled[r.ID()] = *ruleFlags[r.ID()]
+		}
+	}
+	return enabled
+}
+
+// listRules prints the registered rules, one per line.
+func listRules() {
+	for _, r := range vet.Rules() {
+		enabled := "on"
+		if !r.DefaultEnabled() {
+			enabled = "off"
+		}
+		fmt.Printf("%s  %-20s %-8s (default %s)  %s\n", r.ID(), r.Name(), r.DefaultSeverity(), enabled, r.Description())
+	}
+}
+
+// render writes findings to stdout, either as JSON, SARIF, or in the traditional text form.
+// baseline is nil if findings were not compared against a baseline file.
+func render(rules []report.Rule, findings []vet.Finding, baseline *report.Baseline, goMod string) error {
+	switch {
+	case *flagJSON:
+		return report.JSON(os.Stdout, rules, findings, baseline)
+	case *flagSARIF:
+		return report.SARIF(os.Stdout, rules, findings, baseline, goMod)
+	}
+	printFindings(findings)
+	return nil
+}
+
+// printFindings renders findings in the traditional gomodvet text form, one per line,
+// prefixed by their severity.
+// Suppressed and baselined findings are only shown in verbose mode.
+// In verbose mode, the position of the relevant 'go.mod' directive is also shown if known.
+// The chain of requirements that brings in the module is shown for indirect dependencies,
+// or for all dependencies in verbose mode.
+func printFindings(findings []vet.Finding) {
+	for _, f := range findings {
+		if f.Suppression != nil {
+			if *flagVerbose {
+				fmt.Printf("gomodvet: suppressed: %s (reason: %q)\n", f, f.Suppression.Reason)
+			}
+			continue
+		}
+		if f.Baselined {
+			if *flagVerbose {
+				fmt.Printf("gomodvet: baselined: %s\n", f)
+			}
+			continue
+		}
+		fmt.Printf("%s: %s\n", f.Severity, f)
+		if *flagVerbose && f.Pos.IsValid() {
+			fmt.Printf("gomodvet: %s: at %s\n", f.Rule, f.Pos)
+		}
+		if len(f.Chain) > 2 || (*flagVerbose && len(f.Chain) > 0) {
+			fmt.Printf("gomodvet: %s: required via %s\n", f.Rule, strings.Join(f.Chain, " -> "))
+		}
+	}
+}
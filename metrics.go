@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/thepudds/gomodvet/trace"
+	"github.com/thepudds/gomodvet/vet"
+)
+
+// metricsOrder holds the comparison for each -sort key of 'gomodvet metrics', reporting if a sorts before b.
+// Sizes and counts sort from largest to smallest, and depths from shallowest to deepest.
+var metricsOrder = map[string]func(a, b vet.ModuleMetrics) bool{
+	"exclusive": func(a, b vet.ModuleMetrics) bool { return a.Exclusive > b.Exclusive },
+	"fanin":     func(a, b vet.ModuleMetrics) bool { return a.FanIn > b.FanIn },
+	"fanout":    func(a, b vet.ModuleMetrics) bool { return a.FanOut > b.FanOut },
+	"depth":     func(a, b vet.ModuleMetrics) bool { return a.Depth < b.Depth },
+	"size":      func(a, b vet.ModuleMetrics) bool { return a.Size > b.Size },
+	"path":      func(a, b vet.ModuleMetrics) bool { return false },
+}
+
+// metrics implements 'gomodvet metrics [-sort=key]', reporting the fan-in, fan-out, depth,
+// exclusive transitive dependencies, and disk size of each module in the build list.
+func metrics(args []string, tr *trace.Trace) int {
+	fs := flag.NewFlagSet("metrics", flag.ContinueOnError)
+	sortKey := fs.String("sort", "exclusive", "sort by exclusive, fanin, fanout, depth, size, or path")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gomodvet metrics [-sort=key]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		// fs has already reported the error.
+		return ArgErr
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return ArgErr
+	}
+	less := metricsOrder[*sortKey]
+	if less == nil {
+		fmt.Fprintf(os.Stderr, "gomodvet metrics: unknown sort key %q\n", *sortKey)
+		fs.Usage()
+		return ArgErr
+	}
+
+	ctx := context.Background()
+	if *flagTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *flagTimeout)
+		defer cancel()
+	}
+	s, status := moduleSnapshot(ctx, tr)
+	if status != Success {
+		return status
+	}
+	s.Parallel = *flagParallel
+	ms, err := vet.Metrics(ctx, s)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		return InternalErr
+	}
+	sort.SliceStable(ms, func(i, j int) bool {
+		if less(ms[i], ms[j]) {
+			return true
+		}
+		if less(ms[j], ms[i]) {
+			return false
+		}
+		return ms[i].Path < ms[j].Path
+	})
+
+	if *flagJSON {
+		if ms == nil {
+			ms = []vet.ModuleMetrics{} // always emit a list, even if empty
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(ms); err != nil {
+			fmt.Fprintln(os.Stderr, "gomodvet:", err)
+			return InternalErr
+		}
+		return Success
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tVERSION\tDIRECT\tDEPTH\tFAN-IN\tFAN-OUT\tEXCLUSIVE\tSIZE")
+	for _, m := range ms {
+		direct := ""
+		if m.Direct {
+			direct = "yes"
+		}
+		depth := "-"
+		if m.Depth >= 0 {
+			depth = fmt.Sprint(m.Depth)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n", m.Path, m.Version, direct, depth, m.FanIn, m.FanOut, m.Exclusive, byteSize(m.Size))
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		return InternalErr
+	}
+	return Success
+}
+
+// byteSize formats n bytes in the largest unit that keeps it at least 1, such as "1.5 MB".
+func byteSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n) / 1024
+	for _, unit := range []string{"KB", "MB"} {
+		if size < 1024 {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1024
+	}
+	return fmt.Sprintf("%.1f GB", size)
+}
@@ -0,0 +1,19 @@
+//go:build go1.18
+// +build go1.18
+
+package modfile
+
+import "testing"
+
+// FuzzParse checks that ParseData agrees with 'go mod edit -json' for any
+// 'go.mod' content the go command accepts, and that ParseData never panics.
+func FuzzParse(f *testing.F) {
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+	dir := f.TempDir()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseData("go.mod", data)
+		checkMatchesGo(t, dir, data)
+	})
+}
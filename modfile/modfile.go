@@ -5,20 +5,32 @@
 package modfile
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/rogpeppe/go-internal/module"
 )
 
 // File represents the detailed module information in one 'go.mod' file,
 // as returned by 'go mod edit -json <path/to/go.mod>'
 // From: https://golang.org/cmd/go/#hdr-Edit_go_mod_from_tools_or_scripts
+//
+// In addition, the position and any trailing comment of each directive within
+// the 'go.mod' file is recorded.
 type File struct {
-	Module  Module
-	Require []Require
-	Exclude []Module
-	Replace []Replace
+	Filename string `json:"-"` // path to the 'go.mod' file
+	Module   Module
+	Go       string   `json:",omitempty"` // version from the 'go' directive, if any
+	GoPos    Position `json:"-"`          // position of the 'go' directive, if any
+	Require  []Require
+	Exclude  []Module
+	Replace  []Replace
+
+	// Use holds the directories of any 'use' directives, which only appear in 'go.work' files
+	// (which otherwise share the syntax of 'go.mod' files), as the Path of each Module.
+	Use []Module `json:",omitempty"`
 }
 
 // Module represents a 'module' directive in a go.mod file,
@@ -26,7 +38,18 @@ type File struct {
 // Note that a Module here is distinct from and lighterweight than a buildlist.Module.
 type Module struct {
 	Path    string
-	Version string
+	Version string   `json:",omitempty"`
+	Pos     Position `json:"-"` // position of the directive; unset for modules within a Replace
+	Comment string   `json:"-"` // trailing comment text of the directive; unset for modules within a Replace
+}
+
+// String returns m in path@version form, or just the path if there is no version
+// (such as for a filesystem replacement).
+func (m Module) String() string {
+	if m.Version == "" {
+		return m.Path
+	}
+	return m.Path + "@" + m.Version
 }
 
 // Require represents a 'require' directive.
@@ -34,26 +57,172 @@ type Require struct {
 	Path     string
 	Version  string
 	Indirect bool
+	Pos      Position `json:"-"`
+	Comment  string   `json:"-"` // trailing comment text, such as "indirect"
 }
 
 // Replace represents a 'replace' directive.
 type Replace struct {
-	Old Module
-	New Module
+	Old     Module
+	New     Module
+	Pos     Position `json:"-"`
+	Comment string   `json:"-"` // trailing comment text
 }
 
-// Parse returns a GoMod resulting from 'go mod edit -json <path/to/go.mod>'
+// Parse parses the 'go.mod' file at goModFilepath.
+// The result matches what 'go mod edit -json <path/to/go.mod>' reports,
+// with the position of each directive filled in.
 func Parse(goModFilepath string) (File, error) {
-	var result File
-	out, err := exec.Command("go", "mod", "edit", "-json", goModFilepath).Output()
+	data, err := ioutil.ReadFile(goModFilepath)
+	if err != nil {
+		return File{}, err
+	}
+	return ParseData(goModFilepath, data)
+}
 
+// ParseData parses the contents of a 'go.mod' file.
+// filename is used for positions and error messages.
+//
+// Directives not represented in File (such as 'retract' or 'toolchain') are
+// accepted and ignored, as are unknown directives, which allows parsing
+// 'go.mod' files of dependencies written for newer versions of Go.
+func ParseData(filename string, data []byte) (File, error) {
+	lines, err := scan(filename, data)
 	if err != nil {
-		return result, fmt.Errorf("error invoking 'go mod edit -json': %v", err)
+		return File{}, err
+	}
+
+	result := File{Filename: filename}
+	seenModule := false
+	for _, l := range lines {
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", filename, l.pos.Line, fmt.Sprintf(format, args...))
+		}
+		switch l.verb {
+		case "module":
+			if seenModule {
+				return File{}, errorf("repeated module statement")
+			}
+			if len(l.args) != 1 {
+				return File{}, errorf("usage: module module/path")
+			}
+			seenModule = true
+			result.Module = Module{Path: l.args[0], Pos: l.pos, Comment: l.comment}
+		case "go":
+			if len(l.args) != 1 || !goVersionRE.MatchString(l.args[0]) {
+				return File{}, errorf("usage: go 1.23")
+			}
+			result.Go, result.GoPos = l.args[0], l.pos
+		case "require", "exclude":
+			if len(l.args) != 2 {
+				return File{}, errorf("usage: %s module/path v1.2.3", l.verb)
+			}
+			version, err := canonicalVersion(l.args[1])
+			if err != nil {
+				return File{}, errorf("%s %s: %v", l.verb, l.args[0], err)
+			}
+			if l.args[0] == "" {
+				// the 'go' command drops entries with an empty path.
+				continue
+			}
+			if l.verb == "require" {
+				result.Require = append(result.Require, Require{
+					Path:     l.args[0],
+					Version:  version,
+					Indirect: isIndirect(l.comment),
+					Pos:      l.pos,
+					Comment:  l.comment,
+				})
+			} else {
+				result.Exclude = append(result.Exclude, Module{Path: l.args[0], Version: version, Pos: l.pos, Comment: l.comment})
+			}
+		case "use":
+			if len(l.args) != 1 {
+				return File{}, errorf("usage: use local/dir")
+			}
+			result.Use = append(result.Use, Module{Path: l.args[0], Pos: l.pos, Comment: l.comment})
+		case "replace":
+			replace, err := parseReplace(l.args)
+			if err != nil {
+				return File{}, errorf("%v", err)
+			}
+			if replace.Old.Path == "" {
+				continue
+			}
+			replace.Pos, replace.Comment = l.pos, l.comment
+			result.Replace = append(result.Replace, replace)
+		}
+	}
+	return result, nil
+}
+
+// goVersionRE matches the version in a 'go' directive, such as '1.12', '1.21.0', or '1.22rc1'.
+var goVersionRE = regexp.MustCompile(`^([1-9][0-9]*)\.(0|[1-9][0-9]*)(\.(0|[1-9][0-9]*))?([a-z]+[0-9]+)?$`)
+
+// parseReplace parses the arguments of a 'replace' directive, which has the form
+// 'old [version] => new [version]'. A replacement without a version must be a directory path.
+func parseReplace(args []string) (Replace, error) {
+	const usage = "usage: replace module/path [v1.2.3] => other/module v1.4\n\t or replace module/path [v1.2.3] => ../local/directory"
+	arrow := 2
+	if len(args) >= 2 && args[1] == "=>" {
+		arrow = 1
+	}
+	if len(args) < arrow+2 || len(args) > arrow+3 || args[arrow] != "=>" {
+		return Replace{}, fmt.Errorf(usage)
+	}
+
+	var result Replace
+	result.Old.Path = args[0]
+	if arrow == 2 {
+		version, err := canonicalVersion(args[1])
+		if err != nil {
+			return Replace{}, fmt.Errorf("replace %s: %v", args[0], err)
+		}
+		result.Old.Version = version
 	}
 
-	dec := json.NewDecoder(bytes.NewReader(out))
-	if err := dec.Decode(&result); err != nil {
-		return result, fmt.Errorf("error parsing'go mod edit -json': %v", err)
+	result.New.Path = args[arrow+1]
+	if len(args) == arrow+2 {
+		if !isDirectoryPath(result.New.Path) {
+			return Replace{}, fmt.Errorf("replacement module without version must be directory path (rooted or starting with ./ or ../)")
+		}
+		return result, nil
+	}
+	if isDirectoryPath(result.New.Path) {
+		return Replace{}, fmt.Errorf("replacement module directory path must not have version")
+	}
+	version, err := canonicalVersion(args[arrow+2])
+	if err != nil {
+		return Replace{}, fmt.Errorf("replace %s: %v", result.New.Path, err)
 	}
+	result.New.Version = version
 	return result, nil
 }
+
+// canonicalVersion returns the canonical form of a module version,
+// such as 'v1.2.0' for 'v1.2', preserving any '+incompatible' suffix.
+func canonicalVersion(version string) (string, error) {
+	cv := module.CanonicalVersion(version)
+	if cv == "" {
+		return "", fmt.Errorf("invalid module version %q: version must be of the form v1.2.3", version)
+	}
+	return cv, nil
+}
+
+// isIndirect reports if a trailing comment marks a requirement as '// indirect',
+// using the same rules as the go command.
+func isIndirect(comment string) bool {
+	f := strings.Fields(comment)
+	return len(f) == 1 && f[0] == "indirect" || len(f) > 1 && f[0] == "indirect;"
+}
+
+// isDirectoryPath reports if path is a filesystem path rather than a module path,
+// using the same rules as the 'go' command.
+func isDirectoryPath(path string) bool {
+	// Because go.mod files can move from one system to another,
+	// check all known path syntaxes, both Unix and Windows.
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || strings.HasPrefix(path, "/") ||
+		strings.HasPrefix(path, `.\`) || strings.HasPrefix(path, `..\`) || strings.HasPrefix(path, `\`) ||
+		path == "." || path == ".." ||
+		(len(path) >= 2 && ('A' <= path[0] && path[0] <= 'Z' || 'a' <= path[0] && path[0] <= 'z') && path[1] == ':')
+}
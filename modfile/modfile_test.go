@@ -0,0 +1,177 @@
+package modfile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thepudds/gomodvet/gotool"
+)
+
+// seeds are sample 'go.mod' files used by TestParseMatchesGo and as the seed corpus for FuzzParse.
+var seeds = []string{
+	"module example.com/hello\n",
+	`module example.com/hello
+
+require (
+        github.com/go-chi/chi  v3.2.1+incompatible
+        golang.org/x/net v0.0.0-20190110200230-915654e7eabc // indirect
+        example.com/hello/sub v0.0.0
+)
+
+replace example.com/hello/sub => ./sub
+`,
+	`module "example.com/x" // a comment
+
+go 1.21
+
+require (
+	foo.com/a v1.0 // indirect
+	"foo.com/b" v0.4.0-rc2
+	foo.com/c v2.0.0+incompatible //indirect; and more
+)
+exclude foo.com/c v3.2.1+incompatible
+replace foo.com/a => ./a
+replace foo.com/b v1.0.0 => bar.com/b v1.2
+toolchain go1.22.0
+`,
+	"module m\r\nrequire x.org/y v1.2.3\r\nexclude (\r\n\tx.org/y v1.2.2\r\n)\r\n",
+	"// leading comment\nmodule m\n\nrequire ()\nretract v1.0.0 // oops\n",
+}
+
+func TestParse(t *testing.T) {
+	f, err := ParseData("go.mod", []byte(seeds[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := File{
+		Filename: "go.mod",
+		Module:   Module{Path: "example.com/x", Pos: Position{"go.mod", 1, 1}, Comment: "a comment"},
+		Go:       "1.21",
+		GoPos:    Position{"go.mod", 3, 1},
+		Require: []Require{
+			{Path: "foo.com/a", Version: "v1.0.0", Indirect: true, Pos: Position{"go.mod", 6, 2}, Comment: "indirect"},
+			{Path: "foo.com/b", Version: "v0.4.0-rc2", Pos: Position{"go.mod", 7, 2}},
+			{Path: "foo.com/c", Version: "v2.0.0+incompatible", Indirect: true, Pos: Position{"go.mod", 8, 2}, Comment: "indirect; and more"},
+		},
+		Exclude: []Module{
+			{Path: "foo.com/c", Version: "v3.2.1+incompatible", Pos: Position{"go.mod", 10, 1}},
+		},
+		Replace: []Replace{
+			{Old: Module{Path: "foo.com/a"}, New: Module{Path: "./a"}, Pos: Position{"go.mod", 11, 1}},
+			{Old: Module{Path: "foo.com/b", Version: "v1.0.0"}, New: Module{Path: "bar.com/b", Version: "v1.2.0"}, Pos: Position{"go.mod", 12, 1}},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("ParseData() =\n%+v\nwant\n%+v", f, want)
+	}
+
+	// as with the go command, only a token starting with a quote is a quoted string.
+	f, err = ParseData("go.mod", []byte("module example.com/x\ntoolchain go1.\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Module.Path != "example.com/x" {
+		t.Errorf("ParseData().Module.Path = %q, want %q", f.Module.Path, "example.com/x")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, data, wantErr string
+	}{
+		{"repeated module", "module a\nmodule b\n", "go.mod:2: repeated module statement"},
+		{"bad version", "module a\nrequire b.com/c latest\n", "go.mod:2: require b.com/c: invalid module version"},
+		{"replace without version", "module a\nreplace b.com/c => d.com/e\n", "must be directory path"},
+		{"replace dir with version", "module a\nreplace b.com/c => ../e v1.0.0\n", "must not have version"},
+		{"unterminated block", "module a\nrequire (\n\tb.com/c v1.0.0\n", "go.mod:2: unterminated require block"},
+		{"unterminated string", "module \"a\n", "go.mod:1: unterminated quoted string"},
+		{"unexpected character", "module a\vb\n", "go.mod:1: unexpected input character '\\v'"},
+		{"block comment", "module a /* b */\n", "go.mod:1: mod files must use // comments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseData("go.mod", []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseData() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseWork(t *testing.T) {
+	f, err := ParseData("go.work", []byte("go 1.21\n\nuse (\n\t.\n\t./sub // the sub module\n)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Module{
+		{Path: ".", Pos: Position{"go.work", 4, 2}},
+		{Path: "./sub", Pos: Position{"go.work", 5, 2}, Comment: "the sub module"},
+	}
+	if !reflect.DeepEqual(f.Use, want) {
+		t.Errorf("ParseData().Use =\n%+v\nwant\n%+v", f.Use, want)
+	}
+}
+
+func TestParseMatchesGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	dir, err := ioutil.TempDir("", "gomodvet-modfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, seed := range seeds {
+		if !checkMatchesGo(t, dir, []byte(seed)) {
+			t.Errorf("seed %d: 'go mod edit -json' failed", i)
+		}
+	}
+}
+
+// checkMatchesGo reports an error if ParseData and 'go mod edit -json' disagree about data.
+// It returns false if 'go mod edit -json' rejects data, in which case ParseData may
+// either succeed or fail.
+func checkMatchesGo(t *testing.T, dir string, data []byte) bool {
+	t.Helper()
+	goMod := filepath.Join(dir, "go.mod")
+	if err := ioutil.WriteFile(goMod, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := gotool.Exec{Environ: []string{"GOFLAGS="}}.Command(context.Background(), "", "mod", "edit", "-json", goMod)
+	out, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	var want File
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(&want); err != nil {
+		t.Fatalf("decoding 'go mod edit -json' output: %v", err)
+	}
+
+	got, err := ParseData(goMod, data)
+	if err != nil {
+		t.Fatalf("ParseData() error = %v, but 'go mod edit -json' succeeded for:\n%s", err, data)
+	}
+	// compare the JSON forms, which omits positions (not reported by 'go mod edit -json')
+	// and handles invalid UTF-8 the same way.
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = File{}
+	if err := json.Unmarshal(gotJSON, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseData() =\n%+v\n'go mod edit -json' =\n%+v\nfor:\n%s", got, want, data)
+	}
+	return true
+}
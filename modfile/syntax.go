@@ -0,0 +1,193 @@
+package modfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Position describes a location within a 'go.mod' file.
+type Position struct {
+	Filename string // path to the 'go.mod' file
+	Line     int    // line number, starting at 1 (0 if unknown)
+	Column   int    // column number in runes, starting at 1 (0 if unknown)
+}
+
+// IsValid reports if the Position refers to a file.
+func (p Position) IsValid() bool {
+	return p.Filename != ""
+}
+
+// String returns the Position in the form file:line:column,
+// omitting any unknown parts.
+func (p Position) String() string {
+	s := p.Filename
+	if p.Line > 0 {
+		s += fmt.Sprintf(":%d", p.Line)
+		if p.Column > 0 {
+			s += fmt.Sprintf(":%d", p.Column)
+		}
+	}
+	return s
+}
+
+// line is a single directive in a 'go.mod' file, or a single entry
+// within a directive block such as 'require ( ... )'.
+type line struct {
+	verb    string   // directive verb, such as "require". For block entries, the verb of the block.
+	args    []string // remaining tokens, with any quoting removed
+	comment string   // text of any trailing '//' comment, without the '//' and trimmed
+	pos     Position // position of the verb, or of the first token for block entries
+}
+
+// token is a single token on a line, with its 1-based column.
+type token struct {
+	text   string
+	col    int
+	quoted bool // true if text was a quoted string, and hence cannot be a '(' or ')' delimiter
+}
+
+func (t token) is(delim string) bool {
+	return !t.quoted && t.text == delim
+}
+
+// scan splits the contents of a 'go.mod' file into lines.
+// Blank lines, comment-only lines, and block delimiters are not returned.
+//
+// As with the 'go' command, a block starts with a line ending in '(' and ends
+// with a line starting with ')'. Parentheses elsewhere are ordinary tokens.
+func scan(filename string, data []byte) ([]line, error) {
+	var result []line
+	blockVerb := "" // non-empty while inside a block
+	blockLine := 0
+	for i, text := range strings.Split(string(data), "\n") {
+		lineno := i + 1
+		toks, comment, err := tokenize(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+
+		if blockVerb != "" && len(toks) > 0 {
+			if !toks[0].is(")") {
+				pos := Position{Filename: filename, Line: lineno, Column: toks[0].col}
+				result = append(result, line{verb: blockVerb, args: texts(toks), comment: comment, pos: pos})
+				continue
+			}
+			// end of the block. any remaining tokens form a new statement.
+			blockVerb = ""
+			toks = toks[1:]
+		}
+		if len(toks) == 0 {
+			continue
+		}
+
+		pos := Position{Filename: filename, Line: lineno, Column: toks[0].col}
+		n := len(toks)
+		switch {
+		case n >= 2 && toks[n-1].is("("):
+			// start of a block, such as 'require ('.
+			if n > 2 {
+				return nil, fmt.Errorf("%s:%d: unknown block type: %s", filename, lineno, strings.Join(texts(toks[:n-1]), " "))
+			}
+			blockVerb, blockLine = toks[0].text, lineno
+		case n == 3 && toks[1].is("(") && toks[2].is(")"):
+			// empty block on one line, such as 'require ()'.
+		default:
+			result = append(result, line{verb: toks[0].text, args: texts(toks[1:]), comment: comment, pos: pos})
+		}
+	}
+	if blockVerb != "" {
+		return nil, fmt.Errorf("%s:%d: unterminated %s block", filename, blockLine, blockVerb)
+	}
+	return result, nil
+}
+
+// tokenize splits a single line of a 'go.mod' file into tokens,
+// returning any trailing comment separately. Quoted tokens are unquoted.
+func tokenize(text string) ([]token, string, error) {
+	var toks []token
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(text[i:], "//"):
+			return toks, strings.TrimSpace(text[i+2:]), nil
+		case strings.IndexByte("()[]{},", c) >= 0:
+			toks = append(toks, token{text: string(c), col: column(text, i)})
+			i++
+		case c == '"' || c == '`':
+			end := quotedEnd(text, i)
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated quoted string")
+			}
+			s, err := strconv.Unquote(text[i:end])
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid quoted string %s: %v", text[i:end], err)
+			}
+			toks = append(toks, token{text: s, col: column(text, i), quoted: true})
+			i = end
+		default:
+			// as with the go command, a quote within an unquoted token is an ordinary character.
+			start := i
+			for i < len(text) && !strings.HasPrefix(text[i:], "//") {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !isIdent(r) {
+					break
+				}
+				if strings.HasPrefix(text[i:], "/*") {
+					return nil, "", fmt.Errorf("mod files must use // comments (not /* */ comments)")
+				}
+				i += size
+			}
+			if i == start {
+				r, _ := utf8.DecodeRuneInString(text[i:])
+				return nil, "", fmt.Errorf("unexpected input character %#q", r)
+			}
+			toks = append(toks, token{text: text[start:i], col: column(text, start)})
+		}
+	}
+	return toks, "", nil
+}
+
+// quotedEnd returns the index just past the quoted string starting at text[start],
+// or -1 if the string is unterminated.
+func quotedEnd(text string, start int) int {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if quote == '"' {
+				i++ // skip escaped character
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// isIdent reports if r can be part of an unquoted token, using the same rules as the go command.
+func isIdent(r rune) bool {
+	switch r {
+	case ' ', '(', ')', '[', ']', '{', '}', ',':
+		return false
+	}
+	return !unicode.IsSpace(r) && unicode.IsPrint(r)
+}
+
+// column returns the 1-based rune column of the byte offset i in text.
+func column(text string, i int) int {
+	return utf8.RuneCountInString(text[:i]) + 1
+}
+
+func texts(toks []token) []string {
+	var result []string
+	for _, t := range toks {
+		result = append(result, t.text)
+	}
+	return result
+}
@@ -0,0 +1,271 @@
+package modgraph
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rogpeppe/go-internal/semver"
+	"github.com/thepudds/gomodvet/gotool"
+)
+
+// Node is a module version in the requirement graph.
+type Node struct {
+	Path    string // module path, such as "github.com/go-chi/chi"
+	Version string // module version, or the empty string for the main module
+}
+
+// ParseNode parses a node from 'go mod graph', which is in the form path@version,
+// or just path for the main module.
+func ParseNode(s string) Node {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return Node{Path: s[:i], Version: s[i+1:]}
+	}
+	return Node{Path: s}
+}
+
+// String returns n in the form path@version, or just path for the main module.
+func (n Node) String() string {
+	if n.Version == "" {
+		return n.Path
+	}
+	return n.Path + "@" + n.Version
+}
+
+// Edge is a requirement of one module version on another.
+type Edge struct {
+	From Node // the requiring module version
+	To   Node // the required module version
+}
+
+// Graph is the module requirement graph reported by 'go mod graph', with replacements applied.
+// The first node is the main module. A Graph is not modified after it is created,
+// so it is safe for concurrent use.
+type Graph struct {
+	nodes  []Node
+	index  map[Node]int
+	edges  []Edge
+	succ   [][]int
+	pred   [][]int
+	byPath map[string][]int
+}
+
+// Load returns the module requirement graph for the main module, as reported by 'go mod graph':
+// https://golang.org/cmd/go/#hdr-Print_module_requirement_graph
+// dir is the directory in which to run 'go mod graph', with the empty string meaning the
+// current working directory.
+func Load(ctx context.Context, dir string) (*Graph, error) {
+	return LoadWith(ctx, gotool.Exec{}, dir)
+}
+
+// LoadWith is like Load, but runs the go command with t, such as to select
+// the go command and its environment (see gotool.Exec).
+func LoadWith(ctx context.Context, t gotool.Tool, dir string) (*Graph, error) {
+	out, err := t.ModGraph(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(out)
+}
+
+// Parse returns the graph from the output of 'go mod graph'.
+// Requirements on the go version and toolchain (such as "go@1.21.0"), which are not modules,
+// are omitted.
+func Parse(out []byte) (*Graph, error) {
+	g := newGraph()
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("failed to parse line from 'go mod graph': %q", line)
+		}
+		from, to := ParseNode(fields[0]), ParseNode(fields[1])
+		if isGoVersion(from) || isGoVersion(to) {
+			// Go 1.21 and later report requirements on the go version and toolchain, which are not modules.
+			continue
+		}
+		g.addEdge(Edge{From: from, To: to})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// New returns the graph with the given main module and edges, such as a subset of
+// the edges of another graph. Edges are kept in the given order.
+func New(main Node, edges []Edge) *Graph {
+	g := newGraph()
+	g.add(main)
+	for _, e := range edges {
+		g.addEdge(e)
+	}
+	return g
+}
+
+func newGraph() *Graph {
+	return &Graph{index: make(map[Node]int), byPath: make(map[string][]int)}
+}
+
+func isGoVersion(n Node) bool {
+	return n.Path == "go" || n.Path == "toolchain"
+}
+
+// addEdge adds e, first adding its nodes if needed.
+func (g *Graph) addEdge(e Edge) {
+	f, t := g.add(e.From), g.add(e.To)
+	g.edges = append(g.edges, e)
+	g.succ[f] = append(g.succ[f], t)
+	g.pred[t] = append(g.pred[t], f)
+}
+
+// add returns the index of n, first adding it if needed.
+func (g *Graph) add(n Node) int {
+	if i, ok := g.index[n]; ok {
+		return i
+	}
+	i := len(g.nodes)
+	g.nodes = append(g.nodes, n)
+	g.index[n] = i
+	g.succ = append(g.succ, nil)
+	g.pred = append(g.pred, nil)
+	g.byPath[n.Path] = append(g.byPath[n.Path], i)
+	return i
+}
+
+// Main returns the main module, or the zero Node if the graph is empty.
+func (g *Graph) Main() Node {
+	if len(g.nodes) == 0 {
+		return Node{}
+	}
+	return g.nodes[0]
+}
+
+// Nodes returns all nodes, in the order they first appear in 'go mod graph'.
+func (g *Graph) Nodes() []Node {
+	return append([]Node(nil), g.nodes...)
+}
+
+// Edges returns all edges, in the order they appear in 'go mod graph'.
+func (g *Graph) Edges() []Edge {
+	return append([]Edge(nil), g.edges...)
+}
+
+// Has reports if n is in the graph.
+func (g *Graph) Has(n Node) bool {
+	_, ok := g.index[n]
+	return ok
+}
+
+// Lookup returns the nodes for every version of the module path in the graph,
+// sorted by semantic version.
+func (g *Graph) Lookup(path string) []Node {
+	nodes := g.list(g.byPath[path])
+	sort.Slice(nodes, func(i, j int) bool { return semver.Compare(nodes[i].Version, nodes[j].Version) < 0 })
+	return nodes
+}
+
+// Successors returns the module versions required by n.
+func (g *Graph) Successors(n Node) []Node {
+	i, ok := g.index[n]
+	if !ok {
+		return nil
+	}
+	return g.list(g.succ[i])
+}
+
+// Predecessors returns the module versions that require n.
+func (g *Graph) Predecessors(n Node) []Node {
+	i, ok := g.index[n]
+	if !ok {
+		return nil
+	}
+	return g.list(g.pred[i])
+}
+
+func (g *Graph) list(indexes []int) []Node {
+	var nodes []Node
+	for _, i := range indexes {
+		nodes = append(nodes, g.nodes[i])
+	}
+	return nodes
+}
+
+// Walk visits the nodes reachable from start in breadth-first order, starting with start itself,
+// calling visit once for each node along with its depth (the number of edges from start).
+// If visit returns false, Walk does not continue to the successors of that node.
+func (g *Graph) Walk(start Node, visit func(n Node, depth int) bool) {
+	s, ok := g.index[start]
+	if !ok {
+		return
+	}
+	seen := map[int]bool{s: true}
+	queue, depths := []int{s}, []int{0}
+	for len(queue) > 0 {
+		i, depth := queue[0], depths[0]
+		queue, depths = queue[1:], depths[1:]
+		if !visit(g.nodes[i], depth) {
+			continue
+		}
+		for _, j := range g.succ[i] {
+			if !seen[j] {
+				seen[j] = true
+				queue = append(queue, j)
+				depths = append(depths, depth+1)
+			}
+		}
+	}
+}
+
+// Reachable returns the nodes reachable from start, including start itself, in breadth-first order.
+func (g *Graph) Reachable(start Node) []Node {
+	var nodes []Node
+	g.Walk(start, func(n Node, depth int) bool {
+		nodes = append(nodes, n)
+		return true
+	})
+	return nodes
+}
+
+// ShortestPath returns a shortest chain of requirements from from to to, starting with from
+// and ending with to, or nil if to is not reachable from from.
+// Among chains of the same length, the chain following the earliest requirements in 'go mod graph'
+// order is returned, so that the result is deterministic.
+func (g *Graph) ShortestPath(from, to Node) []Node {
+	f, ok := g.index[from]
+	if !ok {
+		return nil
+	}
+	t, ok := g.index[to]
+	if !ok {
+		return nil
+	}
+	parent := map[int]int{f: -1}
+	queue := []int{f}
+	for len(queue) > 0 && !hasKey(parent, t) {
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range g.succ[i] {
+			if !hasKey(parent, j) {
+				parent[j] = i
+				queue = append(queue, j)
+			}
+		}
+	}
+	if !hasKey(parent, t) {
+		return nil
+	}
+	var path []Node
+	for i := t; i >= 0; i = parent[i] {
+		path = append([]Node{g.nodes[i]}, path...)
+	}
+	return path
+}
+
+func hasKey(m map[int]int, k int) bool {
+	_, ok := m[k]
+	return ok
+}
@@ -0,0 +1,89 @@
+package modgraph
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const graphOutput = `example.com/main example.com/a@v1.0.0
+example.com/main example.com/b@v1.1.0
+example.com/main go@1.21.0
+example.com/a@v1.0.0 example.com/c@v0.2.0
+example.com/b@v1.1.0 example.com/c@v0.1.0
+example.com/b@v1.1.0 example.com/d@v2.0.0+incompatible
+example.com/c@v0.2.0 example.com/d@v1.0.0
+go@1.21.0 toolchain@go1.21.0
+`
+
+func TestParse(t *testing.T) {
+	g, err := Parse([]byte(graphOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := Node{Path: "example.com/main"}
+	a := Node{"example.com/a", "v1.0.0"}
+	b := Node{"example.com/b", "v1.1.0"}
+	c2 := Node{"example.com/c", "v0.2.0"}
+	d1 := Node{"example.com/d", "v1.0.0"}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want string
+	}{
+		{"Main", g.Main(), "example.com/main"},
+		{"Nodes", g.Nodes(), "[example.com/main example.com/a@v1.0.0 example.com/b@v1.1.0 example.com/c@v0.2.0 example.com/c@v0.1.0 example.com/d@v2.0.0+incompatible example.com/d@v1.0.0]"},
+		{"len(Edges)", len(g.Edges()), "6"},
+		{"Has", g.Has(d1), "true"},
+		{"Has go", g.Has(Node{"go", "1.21.0"}), "false"},
+		{"Lookup", g.Lookup("example.com/c"), "[example.com/c@v0.1.0 example.com/c@v0.2.0]"},
+		{"Lookup incompatible", g.Lookup("example.com/d"), "[example.com/d@v1.0.0 example.com/d@v2.0.0+incompatible]"},
+		{"Successors", g.Successors(b), "[example.com/c@v0.1.0 example.com/d@v2.0.0+incompatible]"},
+		{"Predecessors", g.Predecessors(a), "[example.com/main]"},
+		{"Predecessors missing", g.Predecessors(Node{"example.com/x", "v1.0.0"}), "[]"},
+		{"Reachable", g.Reachable(a), "[example.com/a@v1.0.0 example.com/c@v0.2.0 example.com/d@v1.0.0]"},
+		{"ShortestPath", g.ShortestPath(main, d1), "[example.com/main example.com/a@v1.0.0 example.com/c@v0.2.0 example.com/d@v1.0.0]"},
+		{"ShortestPath self", g.ShortestPath(c2, c2), "[example.com/c@v0.2.0]"},
+		{"ShortestPath unreachable", g.ShortestPath(b, a), "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(tt.got); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	var depths []string
+	g.Walk(main, func(n Node, depth int) bool {
+		depths = append(depths, fmt.Sprintf("%s:%d", n, depth))
+		return n != b // do not descend into b.
+	})
+	want := "example.com/main:0 example.com/a@v1.0.0:1 example.com/b@v1.1.0:1 example.com/c@v0.2.0:2 example.com/d@v1.0.0:3"
+	if got := strings.Join(depths, " "); got != want {
+		t.Errorf("Walk: got %s, want %s", got, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	if _, err := Parse([]byte("example.com/main\n")); err == nil {
+		t.Errorf("Parse of malformed line succeeded")
+	}
+}
+
+func TestNew(t *testing.T) {
+	main := Node{Path: "example.com/main"}
+	a := Node{"example.com/a", "v1.0.0"}
+	b := Node{"example.com/b", "v1.1.0"}
+	g := New(main, []Edge{{a, b}, {main, a}})
+	if got, want := fmt.Sprint(g.Nodes()), "[example.com/main example.com/a@v1.0.0 example.com/b@v1.1.0]"; got != want {
+		t.Errorf("got nodes %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(g.ShortestPath(main, b)), "[example.com/main example.com/a@v1.0.0 example.com/b@v1.1.0]"; got != want {
+		t.Errorf("got shortest path %s, want %s", got, want)
+	}
+
+	// a main module without requirements.
+	if got := New(main, nil).Main(); got != main {
+		t.Errorf("got main %v, want %v", got, main)
+	}
+}
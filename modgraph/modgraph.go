@@ -1,38 +1,46 @@
 // Package modgraph is an API for gomodvet (a simple prototype of a potential future 'go mod vet' or similar).
-// buildlist uses the context of the active module based on the current working directory to
-// allow examination of the module requirements graph.
+// modgraph uses the context of the active module based on a given directory (or the current
+// working directory) to allow examination of the module requirements graph.
 //
 // See the README at https://github.com/thepudds/gomodvet for more details.
 package modgraph
 
 import (
-	"bufio"
-	"fmt"
-	"os/exec"
-	"strings"
+	"context"
+
+	"github.com/thepudds/gomodvet/gotool"
 )
 
 // Requirements returns a []string of requirements for all dependencies in the build,
 // with replacements applied. The form is module_path@version.
 // This derived from the module requirement graph from 'go mod graph':
 // https://golang.org/cmd/go/#hdr-Print_module_requirement_graph
-func Requirements() ([]string, error) {
-	out, err := exec.Command("go", "mod", "graph").Output()
+// dir is the directory in which to run 'go mod graph', with the empty string meaning the
+// current working directory. See Load for the full graph, including which module requires each requirement.
+func Requirements(ctx context.Context, dir string) ([]string, error) {
+	return RequirementsWith(ctx, gotool.Exec{}, dir)
+}
+
+// RequirementsWith is like Requirements, but runs the go command with t, such as to select
+// the go command and its environment (see gotool.Exec).
+func RequirementsWith(ctx context.Context, t gotool.Tool, dir string) ([]string, error) {
+	out, err := t.ModGraph(ctx, dir)
 	if err != nil {
 		return nil, err
 	}
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
-	results := []string{}
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) != 2 {
-			return nil, fmt.Errorf("failed to parse line from 'go mod graph': %q", line)
-		}
-		results = append(results, fields[1])
-	}
-	if err := scanner.Err(); err != nil {
+	return ParseRequirements(out)
+}
+
+// ParseRequirements returns the requirements from the output of 'go mod graph'.
+// See Requirements for details.
+func ParseRequirements(out []byte) ([]string, error) {
+	g, err := Parse(out)
+	if err != nil {
 		return nil, err
 	}
+	results := []string{}
+	for _, e := range g.Edges() {
+		results = append(results, e.To.String())
+	}
 	return results, nil
 }
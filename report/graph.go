@@ -0,0 +1,173 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/thepudds/gomodvet/vet"
+)
+
+// labelColors are the fill colors for nodes with each vet label. A node with several labels
+// is filled with the color of its first label.
+var labelColors = map[string]string{
+	vet.LabelPseudoVersion: "#fdd0a2",
+	vet.LabelPrerelease:    "#fff3b0",
+	vet.LabelV0:            "#dbe9f6",
+	vet.LabelIncompatible:  "#f4cccc",
+	vet.LabelReplaced:      "#d9ead3",
+}
+
+const highlightColor = "#d62728"
+
+// GraphDocument is the JSON document written by GraphJSON.
+type GraphDocument struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is the JSON form of a vet.GraphNode.
+type GraphNode struct {
+	ID          string   `json:"id"` // path@version, or just path for the main module
+	Path        string   `json:"path"`
+	Version     string   `json:"version,omitempty"`
+	Main        bool     `json:"main,omitempty"`
+	Selected    bool     `json:"selected"`
+	Labels      []string `json:"labels,omitempty"`
+	Highlighted bool     `json:"highlighted,omitempty"`
+}
+
+// GraphEdge is the JSON form of a vet.GraphEdge, referring to nodes by ID.
+type GraphEdge struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Highlighted bool   `json:"highlighted,omitempty"`
+}
+
+// GraphJSON writes g to w as a GraphDocument.
+func GraphJSON(w io.Writer, g *vet.ExportedGraph) error {
+	doc := GraphDocument{Nodes: []GraphNode{}, Edges: []GraphEdge{}} // always emit lists, even if empty
+	for _, n := range g.Nodes {
+		doc.Nodes = append(doc.Nodes, GraphNode{
+			ID:          n.String(),
+			Path:        n.Path,
+			Version:     n.Version,
+			Main:        n.Main,
+			Selected:    n.Selected,
+			Labels:      n.Labels,
+			Highlighted: n.Highlighted,
+		})
+	}
+	for _, e := range g.Edges {
+		doc.Edges = append(doc.Edges, GraphEdge{From: e.From.String(), To: e.To.String(), Highlighted: e.Highlighted})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(doc)
+}
+
+// DOT writes g to w in the Graphviz DOT language, such as for 'dot -Tsvg'.
+// Nodes are filled according to their labels, versions not selected in the build list are dashed,
+// and highlighted nodes and edges are drawn in red.
+func DOT(w io.Writer, g *vet.ExportedGraph) error {
+	var b strings.Builder
+	b.WriteString("digraph modules {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=\"rounded,filled\", fillcolor=white, fontname=Helvetica];\n")
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + strconv.Quote(strings.Join(nodeLines(n), "\n"))}
+		if len(n.Labels) > 0 {
+			attrs = append(attrs, "fillcolor="+strconv.Quote(labelColors[n.Labels[0]]))
+		}
+		if !n.Selected {
+			attrs = append(attrs, `style="rounded,filled,dashed"`, `fontcolor="#888888"`)
+		}
+		switch {
+		case n.Highlighted:
+			attrs = append(attrs, "color="+strconv.Quote(highlightColor), "penwidth=3")
+		case n.Main:
+			attrs = append(attrs, "penwidth=2")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", strconv.Quote(n.String()), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s", strconv.Quote(e.From.String()), strconv.Quote(e.To.String()))
+		if e.Highlighted {
+			fmt.Fprintf(&b, " [color=%s, penwidth=3]", strconv.Quote(highlightColor))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Mermaid writes g to w as a Mermaid flowchart, such as for a Markdown file on GitHub.
+// Nodes are styled as for DOT.
+func Mermaid(w io.Writer, g *vet.ExportedGraph) error {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	ids := make(map[string]string)
+	classes := make(map[string][]string)
+	var highlighted []string
+	for i, n := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[n.String()] = id
+		fmt.Fprintf(&b, "\t%s[\"%s\"]\n", id, strings.Join(nodeLines(n), "<br/>"))
+		if len(n.Labels) > 0 {
+			classes[n.Labels[0]] = append(classes[n.Labels[0]], id)
+		}
+		if !n.Selected {
+			classes["unselected"] = append(classes["unselected"], id)
+		}
+		if n.Highlighted {
+			highlighted = append(highlighted, id)
+		}
+	}
+	var links []string
+	for i, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%s --> %s\n", ids[e.From.String()], ids[e.To.String()])
+		if e.Highlighted {
+			links = append(links, strconv.Itoa(i))
+		}
+	}
+	for _, label := range []string{vet.LabelPseudoVersion, vet.LabelPrerelease, vet.LabelV0, vet.LabelIncompatible, vet.LabelReplaced} {
+		if len(classes[label]) > 0 {
+			name := mermaidClass(label)
+			fmt.Fprintf(&b, "\tclassDef %s fill:%s\n", name, labelColors[label])
+			fmt.Fprintf(&b, "\tclass %s %s\n", strings.Join(classes[label], ","), name)
+		}
+	}
+	if len(classes["unselected"]) > 0 {
+		b.WriteString("\tclassDef unselected stroke-dasharray:5 5,color:#888888\n")
+		fmt.Fprintf(&b, "\tclass %s unselected\n", strings.Join(classes["unselected"], ","))
+	}
+	for _, id := range highlighted {
+		fmt.Fprintf(&b, "\tstyle %s stroke:%s,stroke-width:3px\n", id, highlightColor)
+	}
+	if len(links) > 0 {
+		fmt.Fprintf(&b, "\tlinkStyle %s stroke:%s,stroke-width:3px\n", strings.Join(links, ","), highlightColor)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// nodeLines returns the lines of text shown for n: its path, its version, and its labels.
+func nodeLines(n vet.GraphNode) []string {
+	lines := []string{n.Path}
+	if n.Version != "" {
+		lines = append(lines, n.Version)
+	}
+	if len(n.Labels) > 0 {
+		lines = append(lines, "("+strings.Join(n.Labels, ", ")+")")
+	}
+	return lines
+}
+
+// mermaidClass returns the Mermaid class name for a vet label, which cannot contain a hyphen.
+func mermaidClass(label string) string {
+	return strings.Replace(label, "-", "", -1)
+}
@@ -0,0 +1,130 @@
+// Package report is an API for gomodvet (a simple prototype of a potential future 'go mod vet' or similar).
+// report renders the findings from gomodvet rules in machine-readable formats,
+// and the module requirement graph in formats such as Graphviz DOT.
+//
+// See the README at https://github.com/thepudds/gomodvet for more details.
+package report
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/thepudds/gomodvet/vet"
+)
+
+// SchemaVersion is the version of the JSON document written by JSON.
+// It is incremented for any incompatible change to Document or its contents.
+const SchemaVersion = 1
+
+// Rule describes a gomodvet rule and whether it ran.
+type Rule struct {
+	ID          string `json:"id"`                    // rule ID, such as "gomodvet-004"
+	Name        string `json:"name"`                  // rule name, which is also the gomodvet flag name, such as "conflictingrequires"
+	Description string `json:"description,omitempty"` // help text for the rule
+	Severity    string `json:"severity"`              // default severity of the rule's findings, such as "warning"
+	Ran         bool   `json:"ran"`                   // false if the rule was disabled or skipped
+	Skipped     string `json:"skipped,omitempty"`     // why an enabled rule was skipped, such as "offline"
+}
+
+// Document is the top-level JSON document written by JSON.
+type Document struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Rules         []Rule    `json:"rules"`
+	Findings      []Finding `json:"findings"`
+
+	// Baseline is set if findings were compared against a baseline file.
+	Baseline *Baseline `json:"baseline,omitempty"`
+}
+
+// Baseline describes the comparison of findings against a baseline file.
+type Baseline struct {
+	File string `json:"file"`
+
+	// Fixed lists the baseline entries that no longer match any finding,
+	// and which can be removed from the baseline file.
+	Fixed []vet.BaselineEntry `json:"fixed"`
+}
+
+// Finding is the JSON form of a vet.Finding.
+type Finding struct {
+	Rule     string    `json:"rule"`
+	Severity string    `json:"severity"`
+	Path     string    `json:"path,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Related  []string  `json:"related,omitempty"`
+	Message  string    `json:"message"`
+	Position *Position `json:"position,omitempty"`
+
+	// Chain is a shortest chain of requirements from the main module to the module version
+	// the finding is about, in path@version form.
+	Chain []string `json:"chain,omitempty"`
+
+	// Suppression is set if the finding was suppressed by a '// gomodvet:ignore' comment.
+	Suppression *Suppression `json:"suppression,omitempty"`
+
+	// Baselined is set if the finding is present in the baseline file.
+	Baselined bool `json:"baselined,omitempty"`
+}
+
+// Suppression is the JSON form of a vet.Suppression.
+type Suppression struct {
+	Reason   string    `json:"reason,omitempty"`
+	Position *Position `json:"position,omitempty"`
+}
+
+// Position is the JSON form of a vet.Position.
+type Position struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// JSON writes a Document to w containing findings and a summary of rules.
+// baseline is nil if findings were not compared against a baseline file.
+func JSON(w io.Writer, rules []Rule, findings []vet.Finding, baseline *Baseline) error {
+	doc := Document{
+		SchemaVersion: SchemaVersion,
+		Rules:         rules,
+		Findings:      []Finding{}, // always emit a list, even if empty
+		Baseline:      baseline,
+	}
+	if baseline != nil && baseline.Fixed == nil {
+		b := *baseline
+		b.Fixed = []vet.BaselineEntry{}
+		doc.Baseline = &b
+	}
+	if doc.Rules == nil {
+		doc.Rules = []Rule{}
+	}
+	for _, f := range findings {
+		jf := Finding{
+			Rule:     f.Rule,
+			Severity: f.Severity.String(),
+			Path:     f.Path,
+			Version:  f.Version,
+			Related:  f.Related,
+			Message:  f.Message,
+			Chain:    f.Chain,
+
+			Baselined: f.Baselined,
+		}
+		if f.Pos.IsValid() {
+			jf.Position = position(f.Pos)
+		}
+		if f.Suppression != nil {
+			jf.Suppression = &Suppression{Reason: f.Suppression.Reason}
+			if f.Suppression.Pos.IsValid() {
+				jf.Suppression.Position = position(f.Suppression.Pos)
+			}
+		}
+		doc.Findings = append(doc.Findings, jf)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(doc)
+}
+
+func position(p vet.Position) *Position {
+	return &Position{Filename: p.Filename, Line: p.Line, Column: p.Column}
+}
@@ -0,0 +1,255 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/thepudds/gomodvet/vet"
+)
+
+// SARIF output follows the Static Analysis Results Interchange Format (SARIF) Version 2.1.0:
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+// Only the subset of the format used by gomodvet is defined here.
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	srcRoot      = "%SRCROOT%"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Invocations        []sarifInvocation                `json:"invocations,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level          string                       `json:"level"`
+	Message        sarifMessage                 `json:"message"`
+	AssociatedRule *sarifReportingDescriptorRef `json:"associatedRule,omitempty"`
+}
+
+type sarifReportingDescriptorRef struct {
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string                     `json:"name"`
+	InformationURI string                     `json:"informationUri"`
+	Rules          []sarifReportingDescriptor `json:"rules"`
+}
+
+type sarifReportingDescriptor struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name,omitempty"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	Help             sarifMessage `json:"help"`
+
+	DefaultConfiguration *sarifConfiguration `json:"defaultConfiguration,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+
+	Suppressions  []sarifSuppression `json:"suppressions,omitempty"`
+	BaselineState string             `json:"baselineState,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// SARIF writes findings to w as a SARIF 2.1.0 log with a single run.
+// Each rule becomes a reportingDescriptor, and each finding becomes a result.
+// goMod is the path to the main module's 'go.mod'. Files within the main module's
+// directory are reported relative to it (via the %SRCROOT% base), and findings
+// without a known position are reported against goMod itself.
+// If baseline is not nil, each result has a baselineState, and fixed baseline entries
+// are reported as "absent" results. Skipped rules are reported as notifications of the invocation.
+func SARIF(w io.Writer, rules []Rule, findings []vet.Finding, baseline *Baseline, goMod string) error {
+	root := filepath.Dir(goMod)
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "gomodvet",
+			InformationURI: "https://github.com/thepudds/gomodvet",
+			Rules:          []sarifReportingDescriptor{},
+		}},
+		OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+			srcRoot: {URI: fileURI(root) + "/"},
+		},
+		Results: []sarifResult{},
+	}
+
+	ruleIndex := make(map[string]int)
+	for _, r := range rules {
+		ruleIndex[r.ID] = len(run.Tool.Driver.Rules)
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifReportingDescriptor{
+			ID:               r.ID,
+			Name:             r.Name,
+			ShortDescription: sarifMessage{Text: r.Description},
+			Help:             sarifMessage{Text: r.Description},
+
+			DefaultConfiguration: &sarifConfiguration{Level: sarifLevel(r.Severity)},
+		})
+	}
+
+	var skipped []sarifNotification
+	for _, r := range rules {
+		if r.Skipped != "" {
+			skipped = append(skipped, sarifNotification{
+				Level:          "note",
+				Message:        sarifMessage{Text: "skipped: " + r.Skipped},
+				AssociatedRule: &sarifReportingDescriptorRef{ID: r.ID, Index: ruleIndex[r.ID]},
+			})
+		}
+	}
+	if skipped != nil {
+		run.Invocations = []sarifInvocation{{ExecutionSuccessful: true, ToolExecutionNotifications: skipped}}
+	}
+
+	index := func(rule string) int {
+		i, ok := ruleIndex[rule]
+		if !ok {
+			// a finding for a rule we were not told about; add a minimal descriptor.
+			i = len(run.Tool.Driver.Rules)
+			ruleIndex[rule] = i
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifReportingDescriptor{ID: rule})
+		}
+		return i
+	}
+
+	for _, f := range findings {
+		pos := f.Pos
+		if !pos.IsValid() {
+			pos = vet.Position{Filename: goMod}
+		}
+		loc := sarifPhysicalLocation{ArtifactLocation: artifactLocation(pos.Filename, root)}
+		if pos.Line > 0 {
+			loc.Region = &sarifRegion{StartLine: pos.Line, StartColumn: pos.Column}
+		}
+		result := sarifResult{
+			RuleID:    f.Rule,
+			RuleIndex: index(f.Rule),
+			Level:     sarifLevel(f.Severity.String()),
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: loc}},
+		}
+		if f.Suppression != nil {
+			// a '// gomodvet:ignore' comment is an in-source suppression.
+			result.Suppressions = []sarifSuppression{{Kind: "inSource", Justification: f.Suppression.Reason}}
+		}
+		if baseline != nil {
+			result.BaselineState = "new"
+			if f.Baselined {
+				result.BaselineState = "unchanged"
+			}
+		}
+		run.Results = append(run.Results, result)
+	}
+	if baseline != nil {
+		for _, e := range baseline.Fixed {
+			run.Results = append(run.Results, sarifResult{
+				RuleID:        e.Rule,
+				RuleIndex:     index(e.Rule),
+				Level:         "none",
+				Message:       sarifMessage{Text: e.Message},
+				Locations:     []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: artifactLocation(goMod, root)}}},
+				BaselineState: "absent",
+			})
+		}
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(log)
+}
+
+// sarifLevel maps the name of a vet.Severity, such as "info", to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch severity {
+	case "warning":
+		return "warning"
+	case "info":
+		return "note"
+	}
+	return "error"
+}
+
+// artifactLocation returns a location relative to root if filename is within root,
+// and otherwise an absolute file URI (such as for a 'go.mod' in the module cache).
+func artifactLocation(filename, root string) sarifArtifactLocation {
+	rel, err := filepath.Rel(root, filename)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: srcRoot}
+	}
+	return sarifArtifactLocation{URI: fileURI(filename)}
+}
+
+// fileURI returns a file:// URI for an absolute path.
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths such as C:/foo need a leading slash.
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Path: path}
+	return u.String()
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/rogpeppe/go-internal/goproxytest"
 	"github.com/rogpeppe/go-internal/gotooltest"
 	"github.com/rogpeppe/go-internal/testscript"
 )
 
+// proxyURL is the URL of a Go module proxy serving the modules in testscripts/mod,
+// so that the scripts neither use the network nor depend on old versions of
+// real modules remaining available.
+var proxyURL string
+
 func TestMain(m *testing.M) {
 	os.Exit(testscript.RunMain(gomodvetTestingMain{m}, map[string]func() int{
 		"gomodvet": gomodvetMain,
@@ -19,12 +27,29 @@ type gomodvetTestingMain struct {
 }
 
 func (m gomodvetTestingMain) Run() int {
-	// could do additional setup here if needed (e.g., check or set env vars, start a Go proxy server, etc.)
+	srv, err := goproxytest.NewServer(filepath.Join("testscripts", "mod"), "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot start module proxy:", err)
+		return 1
+	}
+	defer srv.Close()
+	proxyURL = srv.URL
 	return m.m.Run()
 }
 
 func TestScripts(t *testing.T) {
-	p := testscript.Params{Dir: "testscripts"}
+	p := testscript.Params{
+		Dir: "testscripts",
+		Setup: func(env *testscript.Env) error {
+			env.Setenv("GOPROXY", proxyURL)
+			env.Setenv("GOMODVETCACHE", filepath.Join(env.WorkDir, ".gomodvet-cache"))
+			env.Setenv("GOSUMDB", "off")
+			// the scripts were written for early versions of the go command,
+			// which updated 'go.mod' and 'go.sum' as needed.
+			env.Setenv("GOFLAGS", "-mod=mod")
+			return nil
+		},
+	}
 	if err := gotooltest.Setup(&p); err != nil {
 		t.Fatal(err)
 	}
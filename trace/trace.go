@@ -0,0 +1,153 @@
+// Package trace is an API for gomodvet (a simple prototype of a potential future 'go mod vet' or similar).
+// trace records how long each go command and each gomodvet rule takes, which helps diagnose
+// slow runs. A Trace can be written as a table or in the Chrome trace event format.
+//
+// See the README at https://github.com/thepudds/gomodvet for more details.
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Kinds of Event.
+const (
+	KindGo   = "go"   // a go command
+	KindRule = "rule" // a gomodvet rule
+)
+
+// Event is a single timed operation, such as running a go command.
+type Event struct {
+	Kind     string        // KindGo or KindRule
+	Name     string        // the go command line, such as "go mod graph", or the rule, such as "gomodvet-003 (multiplemajor)"
+	Start    time.Time     // when the operation started
+	Duration time.Duration // how long the operation took
+
+	// for KindGo only.
+	ExitCode int // exit code of the go command, or -1 if it did not exit normally
+	Bytes    int // bytes of output (stdout and stderr) from the go command
+}
+
+// Trace records Events. A Trace is safe for concurrent use.
+// A nil *Trace records nothing, so that callers need not check if tracing is enabled.
+type Trace struct {
+	start time.Time
+
+	mu     sync.Mutex
+	events []Event
+}
+
+// New returns an empty Trace starting now.
+func New() *Trace {
+	return &Trace{start: time.Now()}
+}
+
+// Add records e.
+func (t *Trace) Add(e Event) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.events = append(t.events, e)
+	t.mu.Unlock()
+}
+
+// Events returns the recorded events, sorted by start time.
+func (t *Trace) Events() []Event {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	events := append([]Event(nil), t.events...)
+	t.mu.Unlock()
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
+
+// WriteTable writes the events to w as a table, one event per line in start order,
+// with times relative to the start of the trace, followed by the total time.
+func (t *Trace) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "START\tDURATION\tEXIT\tBYTES\t\tKIND  NAME")
+	for _, e := range t.Events() {
+		exit, bytes := "", ""
+		if e.Kind == KindGo {
+			exit, bytes = fmt.Sprint(e.ExitCode), fmt.Sprint(e.Bytes)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\t%-4s  %s\n", seconds(e.Start.Sub(t.start)), seconds(e.Duration), exit, bytes, e.Kind, e.Name)
+	}
+	fmt.Fprintf(tw, "\t%s\t\t\t\t%-4s  %s\n", seconds(time.Since(t.start)), "", "total")
+	return tw.Flush()
+}
+
+// seconds formats d in seconds with millisecond precision, such as "1.234s".
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// chromeEvent is an event in the Chrome trace event format, which can be viewed
+// with chrome://tracing or https://ui.perfetto.dev. See
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type chromeEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Phase string                 `json:"ph"`
+	TS    int64                  `json:"ts"`            // microseconds
+	Dur   int64                  `json:"dur,omitempty"` // microseconds
+	PID   int                    `json:"pid"`
+	TID   int                    `json:"tid"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteChrome writes the events to w as JSON in the Chrome trace event format.
+// Rules and go commands are shown as separate processes, and events that overlap in time,
+// such as rules running concurrently, are placed on separate threads.
+func (t *Trace) WriteChrome(w io.Writer) error {
+	pids := map[string]int{KindRule: 1, KindGo: 2}
+	events := []chromeEvent{
+		{Name: "process_name", Phase: "M", PID: pids[KindRule], Args: map[string]interface{}{"name": "gomodvet rules"}},
+		{Name: "process_name", Phase: "M", PID: pids[KindGo], Args: map[string]interface{}{"name": "go commands"}},
+	}
+	// lanes holds the end time of the last event in each thread, by kind.
+	lanes := make(map[string][]time.Time)
+	for _, e := range t.Events() {
+		end := e.Start.Add(e.Duration)
+		tid := -1
+		for i, laneEnd := range lanes[e.Kind] {
+			if !laneEnd.After(e.Start) {
+				tid = i
+				break
+			}
+		}
+		if tid < 0 {
+			tid = len(lanes[e.Kind])
+			lanes[e.Kind] = append(lanes[e.Kind], time.Time{})
+		}
+		lanes[e.Kind][tid] = end
+
+		ce := chromeEvent{
+			Name:  e.Name,
+			Cat:   e.Kind,
+			Phase: "X",
+			TS:    e.Start.Sub(t.start).Microseconds(),
+			Dur:   e.Duration.Microseconds(),
+			PID:   pids[e.Kind],
+			TID:   tid + 1,
+		}
+		if e.Kind == KindGo {
+			ce.Args = map[string]interface{}{"exitCode": e.ExitCode, "bytes": e.Bytes}
+		}
+		events = append(events, ce)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(struct {
+		TraceEvents     []chromeEvent `json:"traceEvents"`
+		DisplayTimeUnit string        `json:"displayTimeUnit"`
+	}{events, "ms"})
+}
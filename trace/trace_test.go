@@ -0,0 +1,51 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteChrome(t *testing.T) {
+	tr := New()
+	at := func(ms int) time.Time { return tr.start.Add(time.Duration(ms) * time.Millisecond) }
+	// two overlapping rules, each running a go command, and then a third rule.
+	tr.Add(Event{Kind: KindRule, Name: "b", Start: at(1), Duration: 5 * time.Millisecond})
+	tr.Add(Event{Kind: KindRule, Name: "a", Start: at(0), Duration: 10 * time.Millisecond})
+	tr.Add(Event{Kind: KindGo, Name: "go mod graph", Start: at(1), Duration: 2 * time.Millisecond, Bytes: 10})
+	tr.Add(Event{Kind: KindGo, Name: "go list -m all", Start: at(2), Duration: 1 * time.Millisecond, ExitCode: 1})
+	tr.Add(Event{Kind: KindRule, Name: "c", Start: at(6), Duration: 1 * time.Millisecond})
+
+	var buf bytes.Buffer
+	if err := tr.WriteChrome(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		TraceEvents []chromeEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range doc.TraceEvents {
+		if e.Phase == "X" {
+			got = append(got, fmt.Sprintf("%s@%d/%d", e.Name, e.PID, e.TID))
+		}
+	}
+	// events are in start order, with overlapping events of the same kind on separate threads.
+	want := "a@1/1 b@1/2 go mod graph@2/1 go list -m all@2/2 c@1/2"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got events %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestNilTrace(t *testing.T) {
+	var tr *Trace
+	tr.Add(Event{Kind: KindGo, Name: "go env GOMOD"})
+	if len(tr.Events()) != 0 {
+		t.Errorf("nil Trace recorded events")
+	}
+}
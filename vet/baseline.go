@@ -0,0 +1,139 @@
+package vet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// BaselineFileName is the name of the gomodvet baseline file,
+// which is looked for in the same directory as the main module's 'go.mod'.
+const BaselineFileName = ".gomodvet-baseline.json"
+
+// Baseline records a set of previously accepted findings, which allows gomodvet to be adopted
+// by an existing module by only failing for findings that are not already in the baseline.
+type Baseline struct {
+	Findings []BaselineEntry `json:"findings"`
+}
+
+// BaselineEntry identifies a single accepted Finding.
+// Entries are matched against findings by rule, module path, and module version,
+// or by rule and message if the finding is not about a specific module.
+// The message is also recorded so that the baseline file is easier to review.
+type BaselineEntry struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewBaseline returns a Baseline containing findings, excluding any suppressed findings.
+func NewBaseline(findings []Finding) *Baseline {
+	b := &Baseline{Findings: []BaselineEntry{}}
+	seen := make(map[BaselineEntry]bool)
+	for _, f := range findings {
+		if f.Suppression != nil {
+			continue
+		}
+		e := BaselineEntry{Rule: f.Rule, Path: f.Path, Version: f.Version, Message: f.Message}
+		if seen[e.key()] {
+			continue
+		}
+		seen[e.key()] = true
+		b.Findings = append(b.Findings, e)
+	}
+	sort.Slice(b.Findings, func(i, j int) bool {
+		x, y := b.Findings[i], b.Findings[j]
+		if x.Rule != y.Rule {
+			return x.Rule < y.Rule
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		if x.Version != y.Version {
+			return x.Version < y.Version
+		}
+		return x.Message < y.Message
+	})
+	return b
+}
+
+// LoadBaseline reads the baseline file at path.
+// If the file does not exist, LoadBaseline returns nil and no error.
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&b); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, e := range b.Findings {
+		r := Lookup(e.Rule)
+		if r == nil {
+			return nil, fmt.Errorf("%s: unknown rule %q", path, e.Rule)
+		}
+		b.Findings[i].Rule = r.ID()
+	}
+	return &b, nil
+}
+
+// Write writes the baseline file to path.
+func (b *Baseline) Write(path string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(b); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0666)
+}
+
+// Apply compares findings to the baseline, returning findings with the Baselined field
+// set on each Finding present in the baseline. ran reports which rules ran, keyed by rule ID.
+// Apply also returns the baseline entries for rules that ran but that no longer match any finding,
+// which have presumably been fixed and can be removed from the baseline.
+func (b *Baseline) Apply(findings []Finding, ran map[string]bool) (result []Finding, fixed []BaselineEntry) {
+	entries := make(map[BaselineEntry]bool)
+	for _, e := range b.Findings {
+		entries[e.key()] = true
+	}
+	matched := make(map[BaselineEntry]bool)
+	for _, f := range findings {
+		e := BaselineEntry{Rule: f.Rule, Path: f.Path, Version: f.Version, Message: f.Message}
+		if f.Suppression == nil && entries[e.key()] {
+			f.Baselined = true
+			matched[e.key()] = true
+		}
+		result = append(result, f)
+	}
+	for _, e := range b.Findings {
+		if ran[e.Rule] && !matched[e.key()] {
+			fixed = append(fixed, e)
+		}
+	}
+	return result, fixed
+}
+
+// key returns the parts of e used for matching.
+func (e BaselineEntry) key() BaselineEntry {
+	if e.Path != "" {
+		e.Message = ""
+	}
+	return e
+}
+
+// String returns the entry in the traditional gomodvet text form.
+func (e BaselineEntry) String() string {
+	return e.Rule + ": " + e.Message
+}
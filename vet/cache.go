@@ -0,0 +1,269 @@
+package vet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thepudds/gomodvet/gotool"
+	"github.com/thepudds/gomodvet/modfile"
+)
+
+// cacheVersion is incremented for any incompatible change to the contents of the cache,
+// including changes to the rules that would alter their findings.
+const cacheVersion = "gomodvet-cache-1"
+
+// cacheEnv lists the 'go env' variables that can affect the build list or requirement graph,
+// along with identifying the Go toolchain, which are part of each cache key.
+var cacheEnv = []string{"GOVERSION", "GOROOT", "GOFLAGS", "GOPROXY", "GOPRIVATE", "GONOPROXY", "GONOSUMDB", "GOINSECURE", "GOWORK"}
+
+// Cache is an on-disk cache of the information loaded for a module, along with the findings
+// of each CacheableRule, keyed by a hash of the main module's 'go.mod' and 'go.sum' files
+// (along with the other files that shape the build list, such as the 'go.mod' of a directory
+// replacement), the Go version, and the relevant go environment variables. An unchanged module
+// can then be vetted without loading the build list and requirement graph again.
+// Entries that have not been used recently are removed as new entries are added.
+type Cache struct {
+	Dir string // directory holding the cache entries
+}
+
+// CacheEnvVar is the environment variable that overrides the directory returned by DefaultCacheDir,
+// such as to place the cache in a directory that is preserved between CI jobs.
+const CacheEnvVar = "GOMODVETCACHE"
+
+// DefaultCacheDir returns the default directory for the Cache, which is $GOMODVETCACHE if set,
+// or else a "gomodvet" directory within the user's cache directory (see os.UserCacheDir).
+func DefaultCacheDir() (string, error) {
+	if dir := os.Getenv(CacheEnvVar); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gomodvet"), nil
+}
+
+// Clean removes all entries from the cache.
+func (c *Cache) Clean() error {
+	return os.RemoveAll(c.Dir)
+}
+
+// cacheEntry is the cached information for one module.
+type cacheEntry struct {
+	ListModules []byte               `json:"listModules,omitempty"` // output of 'go list -json -m all'
+	ModGraph    []byte               `json:"modGraph,omitempty"`    // output of 'go mod graph'
+	Findings    map[string][]Finding `json:"findings"`              // findings of each CacheableRule, keyed by rule ID
+}
+
+// cached returns the cached findings for r, if r is a CacheableRule present in e.
+// e may be nil, in which case there are no cached findings.
+func (e *cacheEntry) cached(r Rule) ([]Finding, bool) {
+	if e == nil || !Cacheable(r) {
+		return nil, false
+	}
+	findings, ok := e.Findings[r.ID()]
+	return findings, ok
+}
+
+// key returns the cache key for the main module at goMod. Along with the main module's
+// 'go.mod' and 'go.sum', the key covers the other files that shape the build list:
+// the 'go.mod' of each directory replacement, and in workspace mode, the 'go.work' and
+// 'go.work.sum' files and the 'go.mod' of each module in the workspace.
+func (c *Cache) key(ctx context.Context, s *Snapshot, goMod string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", cacheVersion, goMod)
+	env, err := s.Tool.Env(ctx, s.Dir, cacheEnv...)
+	if err != nil {
+		return "", err
+	}
+	h.Write(env)
+
+	// hash reads and hashes the file at name, recording whether it exists,
+	// and returns its contents.
+	hash := func(name string) ([]byte, error) {
+		data, err := s.Tool.ReadModFile(ctx, name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		fmt.Fprintf(h, "%s %v %d\n", name, err == nil, len(data))
+		h.Write(data)
+		return data, nil
+	}
+	// hashModFile hashes the 'go.mod' (or 'go.work') file at name, along with the 'go.mod'
+	// of each of its directory replacements.
+	hashModFile := func(name string) (modfile.File, error) {
+		data, err := hash(name)
+		if err != nil || data == nil {
+			return modfile.File{}, err
+		}
+		file, err := modfile.ParseData(name, data)
+		if err != nil {
+			return modfile.File{}, err
+		}
+		for _, r := range file.Replace {
+			if r.New.Version == "" {
+				if _, err := hash(filepath.Join(localDir(name, r.New.Path), "go.mod")); err != nil {
+					return modfile.File{}, err
+				}
+			}
+		}
+		return file, nil
+	}
+
+	if _, err := hashModFile(goMod); err != nil {
+		return "", err
+	}
+	if _, err := hash(filepath.Join(filepath.Dir(goMod), "go.sum")); err != nil {
+		return "", err
+	}
+	// GOWORK is last in cacheEnv.
+	lines := strings.Split(strings.TrimSuffix(string(env), "\n"), "\n")
+	if goWork := strings.TrimSpace(lines[len(lines)-1]); len(lines) == len(cacheEnv) && goWork != "" && goWork != "off" {
+		work, err := hashModFile(goWork)
+		if err != nil {
+			return "", err
+		}
+		if _, err := hash(goWork + ".sum"); err != nil {
+			return "", err
+		}
+		for _, u := range work.Use {
+			if _, err := hashModFile(filepath.Join(localDir(goWork, u.Path), "go.mod")); err != nil {
+				return "", err
+			}
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// localDir returns the directory path from a 'replace' or 'use' directive in the file at name,
+// which is relative to the directory holding the file unless it is absolute.
+func localDir(name, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(name), path)
+}
+
+// get returns the entry for key, or nil if there is none.
+// An unreadable entry is treated as missing, so that it is replaced by put.
+// The entry's modification time is updated, so that trim keeps recently used entries.
+func (c *Cache) get(key string) *cacheEntry {
+	name := filepath.Join(c.Dir, key+".json")
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Findings == nil {
+		return nil
+	}
+	t := time.Now()
+	os.Chtimes(name, t, t)
+	return &e
+}
+
+// put records the entry for key. The entry is written to a temporary file and renamed,
+// so that concurrent gomodvet processes do not see a partially written entry.
+func (c *Cache) put(key string, e *cacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.Dir, key+".json"))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return c.trim(time.Now())
+}
+
+// Limits on the entries kept by trim.
+const (
+	cacheMaxEntries = 200                 // the most recently used entries to keep
+	cacheMaxAge     = 30 * 24 * time.Hour // remove entries not used for this long
+)
+
+// trim removes the entries not used within cacheMaxAge of now, and then the least recently
+// used entries beyond cacheMaxEntries, so that the cache does not grow without bound as
+// modules change. Leftover temporary files from put are removed along with old entries.
+func (c *Cache) trim(now time.Time) error {
+	infos, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	// most recently used first.
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().After(infos[j].ModTime()) })
+	entries := 0
+	for _, info := range infos {
+		old := now.Sub(info.ModTime()) > cacheMaxAge
+		if strings.HasSuffix(info.Name(), ".json") && !old {
+			entries++
+			old = entries > cacheMaxEntries
+		}
+		if old {
+			os.Remove(filepath.Join(c.Dir, info.Name()))
+		}
+	}
+	return nil
+}
+
+// cacheTool is a gotool.Tool that returns the build list and requirement graph from a cache entry,
+// recording them in the entry if they are not yet present.
+type cacheTool struct {
+	gotool.Tool
+
+	mu    sync.Mutex
+	entry *cacheEntry
+}
+
+func (t *cacheTool) ListModules(ctx context.Context, dir string, upgrades bool) ([]byte, error) {
+	if upgrades {
+		return t.Tool.ListModules(ctx, dir, upgrades)
+	}
+	return t.load(&t.entry.ListModules, func() ([]byte, error) { return t.Tool.ListModules(ctx, dir, upgrades) })
+}
+
+func (t *cacheTool) ModGraph(ctx context.Context, dir string) ([]byte, error) {
+	return t.load(&t.entry.ModGraph, func() ([]byte, error) { return t.Tool.ModGraph(ctx, dir) })
+}
+
+// load returns *out, first setting it by calling f if it is not yet set.
+func (t *cacheTool) load(out *[]byte, f func() ([]byte, error)) ([]byte, error) {
+	t.mu.Lock()
+	data := *out
+	t.mu.Unlock()
+	if data != nil {
+		return data, nil
+	}
+	data, err := f()
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	*out = data
+	t.mu.Unlock()
+	return data, nil
+}
@@ -0,0 +1,157 @@
+package vet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// ConfigFileName is the name of the gomodvet configuration file,
+// which is looked for in the same directory as the main module's 'go.mod'.
+const ConfigFileName = ".gomodvet.json"
+
+// Config holds per-repository settings for gomodvet rules. An example '.gomodvet.json':
+//
+//	{
+//		"rules": {
+//			"gomodvet-002": {"enabled": false},
+//			"gomodvet-007": {"severity": "warning", "allow": ["golang.org/x/..."]},
+//			"replace": {"allow": ["example.com/internal/fork"]}
+//		}
+//	}
+//
+// Rules may be referred to by ID or by name.
+type Config struct {
+	Rules map[string]RuleConfig `json:"rules"`
+}
+
+// RuleConfig holds the settings for a single rule.
+type RuleConfig struct {
+	// Enabled overrides whether the rule runs by default.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Severity overrides the severity of the rule's findings ("error", "warning", or "info").
+	Severity string `json:"severity,omitempty"`
+
+	// Allow lists module path patterns for which the rule's findings are dropped.
+	// A pattern is a module path, optionally using path.Match wildcards.
+	// A pattern ending in "/..." also matches any module path under that prefix.
+	Allow []string `json:"allow,omitempty"`
+}
+
+// LoadConfig reads the configuration file at path.
+// If the file does not exist, LoadConfig returns an empty Config.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(path, data)
+}
+
+// ParseConfig parses the contents of a configuration file.
+// filename is used in error messages.
+func ParseConfig(filename string, data []byte) (*Config, error) {
+	var c Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for key, rc := range c.Rules {
+		if Lookup(key) == nil {
+			return nil, fmt.Errorf("%s: unknown rule %q", filename, key)
+		}
+		if rc.Severity != "" {
+			if _, err := ParseSeverity(rc.Severity); err != nil {
+				return nil, fmt.Errorf("%s: rule %q: %v", filename, key, err)
+			}
+		}
+		for _, pattern := range rc.Allow {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: rule %q: invalid allow pattern %q", filename, key, pattern)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// Rule returns the settings for r, which may be keyed by r's ID or name.
+func (c *Config) Rule(r Rule) RuleConfig {
+	if rc, ok := c.Rules[r.ID()]; ok {
+		return rc
+	}
+	return c.Rules[r.Name()]
+}
+
+// Enabled reports if r should run according to the configuration,
+// falling back to r.DefaultEnabled.
+func (c *Config) Enabled(r Rule) bool {
+	if enabled := c.Rule(r).Enabled; enabled != nil {
+		return *enabled
+	}
+	return r.DefaultEnabled()
+}
+
+// Severity returns the severity of r's findings according to the configuration,
+// falling back to r.DefaultSeverity.
+func (c *Config) Severity(r Rule) Severity {
+	if sev, err := ParseSeverity(c.Rule(r).Severity); err == nil {
+		return sev
+	}
+	return r.DefaultSeverity()
+}
+
+// Apply applies the settings for r to findings reported by r,
+// dropping allowed modules and overriding severities as configured.
+func (c *Config) Apply(r Rule, findings []Finding) []Finding {
+	rc := c.Rule(r)
+	var result []Finding
+	for _, f := range findings {
+		if rc.allowed(f.Path) {
+			continue
+		}
+		if rc.Severity != "" {
+			f.Severity, _ = ParseSeverity(rc.Severity) // validated by ParseConfig
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// allowed reports if modPath matches any of the Allow patterns.
+func (rc RuleConfig) allowed(modPath string) bool {
+	if modPath == "" {
+		return false
+	}
+	for _, pattern := range rc.Allow {
+		if strings.HasSuffix(pattern, "/...") {
+			prefix := strings.TrimSuffix(pattern, "/...")
+			if modPath == prefix || strings.HasPrefix(modPath, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, modPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSeverity returns the Severity named by s ("error", "warning", or "info").
+func ParseSeverity(s string) (Severity, error) {
+	for _, sev := range []Severity{SeverityError, SeverityWarning, SeverityInfo} {
+		if s == sev.String() {
+			return sev, nil
+		}
+	}
+	return SeverityError, fmt.Errorf("invalid severity %q: must be error, warning, or info", s)
+}
@@ -2,16 +2,19 @@ package vet
 
 import (
 	"bufio"
+	"context"
 	"fmt"
-	"os/exec"
 	"strings"
+
+	"github.com/thepudds/gomodvet/gotool"
 )
 
 // TODO: trim these other utils? Keep for now?
 
-// goListDepDirs returns a []string of dirs for all dependencies of pkg
-func goListDepDirs(pkg string) ([]string, error) {
-	out, err := exec.Command("go", "list", "-deps", "-f", "{{.Dir}}", pkg).Output()
+// goListDepDirs returns a []string of dirs for all dependencies of pkg,
+// running 'go list' in dir with the go command and environment of e
+func goListDepDirs(ctx context.Context, e gotool.Exec, dir, pkg string) ([]string, error) {
+	out, err := e.Run(ctx, dir, "list", "-deps", "-f", "{{.Dir}}", pkg)
 	if err != nil {
 		return nil, err
 	}
@@ -26,9 +29,10 @@ func goListDepDirs(pkg string) ([]string, error) {
 	return results, nil
 }
 
-// goListDir returns the dir for a package import path
-func goListDir(pkgPath string) (string, error) {
-	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkgPath).Output()
+// goListDir returns the dir for a package import path,
+// running 'go list' in dir with the go command and environment of e
+func goListDir(ctx context.Context, e gotool.Exec, dir, pkgPath string) (string, error) {
+	out, err := e.Run(ctx, dir, "list", "-f", "{{.Dir}}", pkgPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to find directory of %v: %v", pkgPath, err)
 	}
@@ -0,0 +1,76 @@
+package vet
+
+import (
+	"fmt"
+
+	"github.com/thepudds/gomodvet/modfile"
+)
+
+// Finding is a single issue reported by a gomodvet rule.
+// Rules return Findings rather than printing them, which allows
+// callers to render results however they wish (text, JSON, etc.).
+type Finding struct {
+	Rule     string   // rule ID, such as "gomodvet-004"
+	Severity Severity // how serious the finding is; rules usually leave it unset (see NewRule)
+	Path     string   // module path the finding is about, if any
+	Version  string   // module version the finding is about, if any
+	Message  string   // human-readable description, without the rule ID prefix
+	Related  []string // other modules involved, in path@version form
+	Pos      Position // location of the relevant directive in a 'go.mod' file, if known
+
+	// Chain is a shortest chain of requirements from the main module to the module version
+	// the Finding is about, in path@version form (or just path for the main module), if known.
+	// For example, a Finding about an indirect dependency c might have a Chain of
+	// ["example.com/main", "example.com/a@v1.0.0", "example.com/c@v0.1.0"].
+	Chain []string
+
+	// Suppression is set if the Finding was suppressed by a '// gomodvet:ignore' comment.
+	// Suppressed findings are still reported, but should not be treated as problems.
+	Suppression *Suppression
+
+	// Baselined is set if the Finding is present in the baseline file.
+	// Baselined findings are still reported, but should not be treated as new problems.
+	Baselined bool
+}
+
+// String returns the Finding in the traditional gomodvet text form,
+// such as "gomodvet-006: a module is using a prerelease version: foo v1.0.0-rc1".
+func (f Finding) String() string {
+	return f.Rule + ": " + f.Message
+}
+
+// Severity classifies how serious a Finding is.
+// The zero value is SeverityDefault.
+type Severity int
+
+const (
+	// SeverityDefault is the severity of a Finding that does not override the
+	// DefaultSeverity of its rule. Run replaces it with the rule's severity.
+	SeverityDefault Severity = iota
+	SeverityError
+	SeverityWarning
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityDefault:
+		return "default"
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// AtLeast reports if s is as serious as or more serious than t.
+// For example, SeverityError is at least SeverityWarning.
+func (s Severity) AtLeast(t Severity) bool {
+	return s <= t
+}
+
+// Position describes a location within a 'go.mod' file.
+type Position = modfile.Position
@@ -0,0 +1,174 @@
+package vet
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rogpeppe/go-internal/semver"
+	"github.com/thepudds/gomodvet/modgraph"
+)
+
+// Labels for the module versions in an ExportedGraph that the gomodvet rules commonly report.
+// A node can have several labels, which are listed in this order.
+const (
+	LabelPseudoVersion = "pseudo-version"
+	LabelPrerelease    = "prerelease"
+	LabelV0            = "v0"
+	LabelIncompatible  = "incompatible"
+	LabelReplaced      = "replaced"
+)
+
+// GraphOptions controls which parts of the module requirement graph ExportGraph returns.
+type GraphOptions struct {
+	// BuildList collapses the graph to the versions selected in the build list, with each
+	// requirement pointing to the selected version of the required module.
+	BuildList bool
+
+	// Highlight is a module path. If set, every node and edge on a chain of requirements
+	// from the main module to any version of the module is highlighted.
+	Highlight string
+}
+
+// ExportedGraph is the module requirement graph annotated for display, as returned by ExportGraph.
+type ExportedGraph struct {
+	Nodes []GraphNode // the main module is first
+	Edges []GraphEdge
+}
+
+// GraphNode is a module version in an ExportedGraph.
+type GraphNode struct {
+	modgraph.Node
+	Main        bool     // the main module
+	Selected    bool     // the version is in the build list
+	Labels      []string // such as LabelPrerelease
+	Highlighted bool     // on a chain of requirements to GraphOptions.Highlight
+}
+
+// GraphEdge is a requirement in an ExportedGraph.
+type GraphEdge struct {
+	modgraph.Edge
+	Highlighted bool // on a chain of requirements to GraphOptions.Highlight
+}
+
+// ExportGraph returns the module requirement graph with each node labeled by the kinds of version
+// the gomodvet rules report, such as prereleases, for rendering by the report package.
+// If opts.Highlight is not in the graph, ExportGraph returns a *UsageError.
+func ExportGraph(ctx context.Context, s *Snapshot, opts GraphOptions) (*ExportedGraph, error) {
+	g, err := s.Graph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := s.BuildList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]string)
+	for _, mod := range mods {
+		selected[mod.Path] = mod.Version
+		if mod.Main && len(g.Nodes()) == 0 {
+			// 'go mod graph' reports nothing for a module without requirements,
+			// but the main module is always in the exported graph.
+			g = modgraph.New(modgraph.Node{Path: mod.Path}, nil)
+		}
+	}
+	if opts.BuildList {
+		g = collapse(g, selected)
+	}
+
+	highlighted := make(map[modgraph.Node]bool)
+	if opts.Highlight != "" {
+		targets := g.Lookup(opts.Highlight)
+		if len(targets) == 0 {
+			return nil, &UsageError{Err: fmt.Errorf("module %s is not in the requirement graph", opts.Highlight)}
+		}
+		highlighted = chainsTo(g, targets)
+	}
+
+	eg := &ExportedGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for i, n := range g.Nodes() {
+		v, ok := selected[n.Path]
+		gn := GraphNode{Node: n, Main: i == 0, Selected: ok && v == n.Version, Highlighted: highlighted[n]}
+		if !gn.Main {
+			gn.Labels = versionLabels(n.Version)
+			r, err := s.replacement(ctx, n.Path, n.Version)
+			if err != nil {
+				return nil, err
+			}
+			if r != nil {
+				gn.Labels = append(gn.Labels, LabelReplaced)
+			}
+		}
+		eg.Nodes = append(eg.Nodes, gn)
+	}
+	for _, e := range g.Edges() {
+		eg.Edges = append(eg.Edges, GraphEdge{Edge: e, Highlighted: highlighted[e.From] && highlighted[e.To]})
+	}
+	return eg, nil
+}
+
+// collapse returns the graph of the requirements of the selected module versions,
+// with each requirement pointing to the selected version of the required module.
+func collapse(g *modgraph.Graph, selected map[string]string) *modgraph.Graph {
+	var edges []modgraph.Edge
+	seen := make(map[modgraph.Edge]bool)
+	for _, e := range g.Edges() {
+		if v, ok := selected[e.From.Path]; !ok || v != e.From.Version {
+			continue
+		}
+		v, ok := selected[e.To.Path]
+		if !ok || e.To.Path == e.From.Path {
+			continue
+		}
+		e.To.Version = v
+		if !seen[e] {
+			seen[e] = true
+			edges = append(edges, e)
+		}
+	}
+	return modgraph.New(g.Main(), edges)
+}
+
+// chainsTo returns the nodes on a chain of requirements from the main module to any of targets,
+// which are those both reachable from the main module and from which a target is reachable.
+func chainsTo(g *modgraph.Graph, targets []modgraph.Node) map[modgraph.Node]bool {
+	reaches := make(map[modgraph.Node]bool)
+	queue := append([]modgraph.Node(nil), targets...)
+	for _, n := range targets {
+		reaches[n] = true
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, p := range g.Predecessors(n) {
+			if !reaches[p] {
+				reaches[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	on := make(map[modgraph.Node]bool)
+	for _, n := range g.Reachable(g.Main()) {
+		if reaches[n] {
+			on[n] = true
+		}
+	}
+	return on
+}
+
+// versionLabels returns the labels for a module version, other than LabelReplaced.
+func versionLabels(version string) []string {
+	var labels []string
+	switch {
+	case isPseudoVersion(version):
+		labels = append(labels, LabelPseudoVersion)
+	case isPrerelease(version):
+		labels = append(labels, LabelPrerelease)
+	}
+	if semver.Major(version) == "v0" {
+		labels = append(labels, LabelV0)
+	}
+	if semver.Build(version) == "+incompatible" {
+		labels = append(labels, LabelIncompatible)
+	}
+	return labels
+}
@@ -0,0 +1,121 @@
+package vet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/thepudds/gomodvet/modgraph"
+)
+
+// ModuleMetrics describes the weight of a module in the build list, as reported by Metrics.
+type ModuleMetrics struct {
+	Path    string
+	Version string
+	Direct  bool // required by the main module
+
+	// FanIn and FanOut are the number of modules in the build list requiring and
+	// required by the module.
+	FanIn  int
+	FanOut int
+
+	// Depth is the length of the shortest chain of requirements from the main module,
+	// which is 1 for a direct dependency, or -1 if the module is not reachable.
+	Depth int
+
+	// Exclusive is the number of other modules in the build list that are only reachable from
+	// the main module through this module, and would be dropped along with it.
+	Exclusive int
+
+	// Size is the disk space in bytes used by the module's directory, which is typically
+	// in the module cache, or 0 if the module has not been downloaded.
+	Size int64
+}
+
+// Metrics returns the metrics of each module in the build list other than the main module,
+// in build list order. The metrics are computed on the module requirement graph collapsed
+// to the selected versions (see GraphOptions.BuildList).
+func Metrics(ctx context.Context, s *Snapshot) ([]ModuleMetrics, error) {
+	mods, err := s.BuildList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g, err := s.Graph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]string)
+	for _, mod := range mods {
+		selected[mod.Path] = mod.Version
+	}
+	g = collapse(g, selected)
+	main := g.Main()
+
+	depths := make(map[modgraph.Node]int)
+	g.Walk(main, func(n modgraph.Node, depth int) bool {
+		depths[n] = depth
+		return true
+	})
+
+	var metrics []ModuleMetrics
+	var dirs []string
+	for _, mod := range mods {
+		if mod.Main {
+			continue
+		}
+		n := modgraph.Node{Path: mod.Path, Version: mod.Version}
+		m := ModuleMetrics{
+			Path:    mod.Path,
+			Version: mod.Version,
+			FanIn:   len(g.Predecessors(n)),
+			FanOut:  len(g.Successors(n)),
+			Depth:   -1,
+		}
+		if d, ok := depths[n]; ok {
+			m.Depth = d
+			m.Direct = d == 1
+			m.Exclusive = len(depths) - 1 - reachableWithout(g, main, n)
+		}
+		dir := mod.Dir
+		if dir == "" && mod.Replace != nil {
+			dir = mod.Replace.Dir
+		}
+		metrics = append(metrics, m)
+		dirs = append(dirs, dir)
+	}
+
+	forEach(len(metrics), s.Parallel, func(i int) {
+		if dirs[i] != "" {
+			metrics[i].Size = dirSize(dirs[i])
+		}
+	})
+	return metrics, nil
+}
+
+// reachableWithout returns the number of nodes reachable from main without passing through n,
+// including main itself. This is a simple quadratic approach overall, but even large build lists
+// have at most a few thousand modules.
+func reachableWithout(g *modgraph.Graph, main, n modgraph.Node) int {
+	count := 0
+	g.Walk(main, func(m modgraph.Node, depth int) bool {
+		if m == n {
+			return false
+		}
+		count++
+		return true
+	})
+	return count
+}
+
+// dirSize returns the total size of the regular files within dir. Files that cannot be read,
+// such as due to a concurrent 'go clean -modcache', are ignored.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,30 @@
+package vet
+
+import (
+	"runtime"
+	"sync"
+)
+
+// forEach calls f(i) for each i from 0 to n-1, with at most limit calls running concurrently,
+// and returns once all calls have returned. A limit of zero means runtime.GOMAXPROCS(0).
+// Callers typically have f store its results at index i, so that the results are
+// in a deterministic order regardless of the order in which the calls complete.
+func forEach(n, limit int, f func(i int)) {
+	if limit <= 0 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
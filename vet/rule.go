@@ -0,0 +1,159 @@
+package vet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Rule is a single gomodvet check, such as reporting the use of pseudo-versions.
+// The built-in rules are registered automatically, and additional rules
+// can be added with Register.
+type Rule interface {
+	ID() string                // rule ID, such as "gomodvet-004"
+	Name() string              // short name, also used as the gomodvet flag name, such as "conflictingrequires"
+	Description() string       // one-line description, used for flag help and documentation
+	DefaultEnabled() bool      // whether the rule runs unless disabled
+	DefaultSeverity() Severity // typical severity of the rule's findings, which may be overridden by configuration
+	Run(ctx context.Context, s *Snapshot) ([]Finding, error)
+}
+
+// NewRule returns a Rule implemented by the function run.
+// run usually leaves the Severity of each Finding unset, in which case Run uses severity
+// (or the severity from the configuration). A Finding can instead set its own Severity,
+// which the rule's description should mention.
+func NewRule(id, name, description string, enabled bool, severity Severity, run func(ctx context.Context, s *Snapshot) ([]Finding, error)) Rule {
+	return &funcRule{id: id, name: name, description: description, enabled: enabled, severity: severity, run: run}
+}
+
+type funcRule struct {
+	id, name, description string
+	enabled               bool
+	severity              Severity
+	run                   func(ctx context.Context, s *Snapshot) ([]Finding, error)
+}
+
+func (r *funcRule) ID() string                { return r.id }
+func (r *funcRule) Name() string              { return r.name }
+func (r *funcRule) Description() string       { return r.description }
+func (r *funcRule) DefaultEnabled() bool      { return r.enabled }
+func (r *funcRule) DefaultSeverity() Severity { return r.severity }
+
+func (r *funcRule) Run(ctx context.Context, s *Snapshot) ([]Finding, error) { return r.run(ctx, s) }
+
+// NetworkRule is implemented by a Rule that needs network access, such as to query
+// a module proxy for available upgrades. Run skips such rules if Options.Offline is set.
+type NetworkRule interface {
+	Rule
+	NeedsNetwork() bool
+}
+
+// WithNetwork returns r marked as needing network access (see NetworkRule).
+func WithNetwork(r Rule) Rule {
+	return networkRule{r}
+}
+
+type networkRule struct{ Rule }
+
+func (networkRule) NeedsNetwork() bool { return true }
+
+// NeedsNetwork reports if r needs network access (see NetworkRule).
+func NeedsNetwork(r Rule) bool {
+	nr, ok := r.(NetworkRule)
+	return ok && nr.NeedsNetwork()
+}
+
+// CacheableRule is implemented by a Rule whose findings depend only on the module requirement
+// graph and the 'go.mod' files in the build, and not on the main module's packages or the network.
+// Run can then reuse the rule's findings from the on-disk Cache while the main module's 'go.mod'
+// and 'go.sum' files are unchanged.
+type CacheableRule interface {
+	Rule
+	Cacheable() bool
+}
+
+// WithCache returns r marked as cacheable (see CacheableRule).
+func WithCache(r Rule) Rule {
+	return cacheableRule{r}
+}
+
+type cacheableRule struct{ Rule }
+
+func (cacheableRule) Cacheable() bool { return true }
+
+// Cacheable reports if r is cacheable (see CacheableRule).
+func Cacheable(r Rule) bool {
+	cr, ok := r.(CacheableRule)
+	return ok && cr.Cacheable()
+}
+
+// registry holds the registered rules, keyed by ID.
+var registry = make(map[string]Rule)
+
+// Register adds r to the set of rules returned by Rules.
+// It panics if a rule with the same ID or name is already registered.
+// Register is intended to be called from init functions, and is not safe for concurrent use.
+func Register(r Rule) {
+	if Lookup(r.ID()) != nil || Lookup(r.Name()) != nil {
+		panic(fmt.Sprintf("vet: duplicate rule registered: %s (%s)", r.ID(), r.Name()))
+	}
+	registry[r.ID()] = r
+}
+
+// Rules returns all registered rules, sorted by ID.
+func Rules() []Rule {
+	var rules []Rule
+	for _, r := range registry {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID() < rules[j].ID() })
+	return rules
+}
+
+// Lookup returns the registered rule with the given ID or name, or nil if there is none.
+func Lookup(idOrName string) Rule {
+	if r, ok := registry[idOrName]; ok {
+		return r
+	}
+	for _, r := range registry {
+		if r.Name() == idOrName {
+			return r
+		}
+	}
+	return nil
+}
+
+// GoModNeedsUpdateID is the ID of the GoModNeedsUpdate rule.
+// If it is enabled and reports a Finding, other rules should not be run,
+// because their results would not be reliable.
+const GoModNeedsUpdateID = "gomodvet-001"
+
+func init() {
+	Register(NewRule(GoModNeedsUpdateID, "gomodneedsupdate",
+		"report if the current module's 'go.mod' file would be updated by a 'go build' or 'go list'",
+		true, SeverityError, GoModNeedsUpdate))
+	Register(WithNetwork(NewRule("gomodvet-002", "upgrades",
+		"report if the current module has available updates for its dependencies",
+		true, SeverityInfo, Upgrades)))
+	Register(WithCache(NewRule("gomodvet-003", "multiplemajor",
+		"report if a module has multiple major versions in use",
+		true, SeverityWarning, MultipleMajor)))
+	Register(WithCache(NewRule("gomodvet-004", "conflictingrequires",
+		"report if there are requirements for potentially conflicting v0 versions or '+incompatible' versions for different major versions",
+		true, SeverityWarning, ConflictingRequires)))
+	Register(WithCache(NewRule("gomodvet-005", "excludedversion",
+		"report if the current build is using a version excluded by a dependency",
+		true, SeverityError, ExcludedVersion)))
+	Register(WithCache(NewRule("gomodvet-006", "prerelease",
+		"report if the current build is using a prerelease version (exclusive of pseudo-versions, which are reported separately)",
+		true, SeverityWarning, Prerelease)))
+	Register(WithCache(NewRule("gomodvet-007", "pseudoversion",
+		"report if the current build is using a pseudo-version",
+		true, SeverityWarning, PseudoVersion)))
+	Register(NewRule("gomodvet-008", "replace",
+		"report if the main module is using any 'replace' directives (a warning rather than an error for a module with only commands)",
+		true, SeverityError, Replace))
+	Register(NewRule(UnusedSuppressionID, "unusedsuppression",
+		"report '// gomodvet:ignore' comments in the main module's 'go.mod' that do not suppress any finding",
+		true, SeverityWarning, UnusedSuppressions))
+}
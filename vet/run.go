@@ -0,0 +1,290 @@
+package vet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/thepudds/gomodvet/gotool"
+	"github.com/thepudds/gomodvet/modgraph"
+	"github.com/thepudds/gomodvet/trace"
+)
+
+// Options controls Run.
+type Options struct {
+	// Dir is a directory within the module to vet.
+	// The empty string means the current working directory.
+	Dir string
+
+	// Verbose enables additional output from the rules on Out.
+	Verbose bool
+
+	// Out receives diagnostics, such as the additional output enabled by Verbose.
+	// If nil, os.Stdout is used.
+	Out io.Writer
+
+	// Tool runs the go command. If nil, the go command found in PATH is used.
+	Tool gotool.Tool
+
+	// Config holds the rule settings. If nil, Run uses the ConfigFileName file
+	// next to the main module's 'go.mod', if present.
+	Config *Config
+
+	// Enabled overrides whether rules run, keyed by rule ID,
+	// taking precedence over Config.
+	Enabled map[string]bool
+
+	// Offline skips the rules that need network access (see NetworkRule),
+	// recording them in Result.Skipped. If Tool is nil, the go command is also
+	// prevented from accessing the network (see gotool.Exec.Offline).
+	Offline bool
+
+	// Parallel is the maximum number of rules to run concurrently, which also bounds
+	// the concurrency within a rule (see Snapshot.Parallel). Zero means runtime.GOMAXPROCS(0).
+	Parallel int
+
+	// Cache, if not nil, holds the build list, requirement graph, and findings of each
+	// CacheableRule from previous runs, which are reused if the module is unchanged.
+	Cache *Cache
+
+	// Trace, if not nil, records how long each rule takes. If Tool is nil,
+	// Trace also records each invocation of the go command (see gotool.Exec.Trace).
+	Trace *trace.Trace
+}
+
+// SkippedOffline is the Result.Skipped reason for a rule that needs network access
+// when Options.Offline is set.
+const SkippedOffline = "offline"
+
+// Result holds the outcome of Run.
+type Result struct {
+	GoMod    string            // path to the main module's 'go.mod'
+	Config   *Config           // the rule settings used
+	Ran      map[string]bool   // the rules that ran, keyed by rule ID
+	Skipped  map[string]string // why enabled rules were skipped, such as SkippedOffline, keyed by rule ID
+	Cached   map[string]bool   // the rules that ran whose findings came from Options.Cache, keyed by rule ID
+	Findings []Finding         // findings from all rules that ran, including suppressed findings
+}
+
+// ErrNoModule is returned by Run if there is no 'go.mod' for Options.Dir.
+var ErrNoModule = errors.New("no current 'go.mod' file. please run from within a module with module-mode enabled.")
+
+// UsageError reports a problem with the inputs to Run or Why, such as an invalid configuration file
+// or suppression comment, rather than a failure to vet the module.
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string { return e.Err.Error() }
+
+// RuleError reports that a rule failed to run.
+// If the rule was interrupted because the context passed to Run was canceled
+// or timed out, Err is the context's error.
+type RuleError struct {
+	Rule Rule
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	if e.Err == context.DeadlineExceeded {
+		return fmt.Sprintf("%s (%s) timed out", e.Rule.ID(), e.Rule.Name())
+	}
+	return fmt.Sprintf("%s: %v", e.Rule.ID(), e.Err)
+}
+
+// Run runs the enabled rules against the module containing opts.Dir, sharing a single Snapshot.
+// Every go command invoked is bound to ctx, so canceling ctx interrupts Run.
+// GoModNeedsUpdateID runs first, and if it reports a Finding, the remaining rules are
+// not run, because their results would not be reliable. Otherwise, the remaining rules
+// run concurrently (see Options.Parallel), with their findings reported in rule ID order.
+// If opts.Offline is set, rules that need network access are skipped.
+// If opts.Cache is set, information and findings from the cache are used where possible.
+// Each Finding about a specific module version has its Chain set.
+// Findings are filtered by the configuration, and suppression comments in the main module's
+// 'go.mod' are applied (see Suppress).
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	snap := NewSnapshot(opts.Dir, opts.Verbose)
+	snap.Parallel = opts.Parallel
+	snap.Out = opts.Out
+	if opts.Tool != nil {
+		snap.Tool = opts.Tool
+	} else {
+		snap.Tool = gotool.Exec{Offline: opts.Offline, Trace: opts.Trace}
+	}
+
+	goMod, err := snap.GoMod(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if goMod == "" {
+		return nil, ErrNoModule
+	}
+
+	config := opts.Config
+	if config == nil {
+		config, err = LoadConfig(filepath.Join(filepath.Dir(goMod), ConfigFileName))
+		if err != nil {
+			return nil, &UsageError{Err: err}
+		}
+	}
+
+	res := &Result{GoMod: goMod, Config: config, Ran: make(map[string]bool), Skipped: make(map[string]string), Cached: make(map[string]bool)}
+	var rules []Rule
+	for _, r := range Rules() {
+		enabled, ok := opts.Enabled[r.ID()]
+		if !ok {
+			enabled = config.Enabled(r)
+		}
+		if !enabled {
+			continue
+		}
+		if opts.Offline && NeedsNetwork(r) {
+			res.Skipped[r.ID()] = SkippedOffline
+			continue
+		}
+		rules = append(rules, r)
+	}
+
+	var cacheKey string
+	var entry *cacheEntry
+	if opts.Cache != nil {
+		cacheKey, err = opts.Cache.key(ctx, snap, goMod)
+		if err != nil {
+			return nil, err
+		}
+		entry = opts.Cache.get(cacheKey)
+		if entry == nil {
+			entry = &cacheEntry{Findings: make(map[string][]Finding)}
+		} else if opts.Verbose {
+			snap.logf("gomodvet: using cached results for %s\n", goMod)
+		}
+		snap.Tool = &cacheTool{Tool: snap.Tool, entry: entry}
+	}
+
+	if len(rules) > 0 && rules[0].ID() == GoModNeedsUpdateID {
+		findings, err := runRule(ctx, rules[0], snap, opts.Trace)
+		if err := res.add(ctx, rules[0], findings, err); err != nil {
+			return nil, err
+		}
+		rules = rules[1:]
+	}
+	if !res.Stopped() {
+		// the remaining rules are independent, and share snap, which loads
+		// each piece of information once regardless of which rule asks first.
+		findings := make([][]Finding, len(rules))
+		errs := make([]error, len(rules))
+		forEach(len(rules), opts.Parallel, func(i int) {
+			if cached, ok := entry.cached(rules[i]); ok {
+				opts.Trace.Add(trace.Event{Kind: trace.KindRule, Name: ruleName(rules[i]) + " (cached)", Start: time.Now()})
+				findings[i] = cached
+				return
+			}
+			findings[i], errs[i] = runRule(ctx, rules[i], snap, opts.Trace)
+		})
+		for i, r := range rules {
+			if err := res.add(ctx, r, findings[i], errs[i]); err != nil {
+				return nil, err
+			}
+			if _, ok := entry.cached(r); ok {
+				res.Cached[r.ID()] = true
+			}
+		}
+		if entry != nil {
+			for i, r := range rules {
+				if Cacheable(r) {
+					entry.Findings[r.ID()] = findings[i]
+				}
+			}
+			// failing to update the cache only means the next run is slower.
+			if err := opts.Cache.put(cacheKey, entry); err != nil && opts.Verbose {
+				snap.logf("gomodvet: cache: %v\n", err)
+			}
+		}
+	}
+
+	if err := addChains(ctx, snap, res.Findings); err != nil {
+		return nil, err
+	}
+
+	// apply any '// gomodvet:ignore' comments from the main module's 'go.mod'.
+	findings, unused, err := Suppress(ctx, snap, res.Findings, res.Ran)
+	if err != nil {
+		return nil, &UsageError{Err: err}
+	}
+	unusedRule := Lookup(UnusedSuppressionID)
+	res.Findings = append(findings, config.Apply(unusedRule, setSeverity(unusedRule, unused))...)
+	return res, nil
+}
+
+// runRule runs r, recording how long it takes in t. Findings that leave their Severity unset
+// get r's DefaultSeverity; a configured severity is applied later, by Config.Apply.
+func runRule(ctx context.Context, r Rule, s *Snapshot, t *trace.Trace) ([]Finding, error) {
+	start := time.Now()
+	findings, err := r.Run(ctx, s)
+	t.Add(trace.Event{Kind: trace.KindRule, Name: ruleName(r), Start: start, Duration: time.Since(start)})
+	return setSeverity(r, findings), err
+}
+
+// setSeverity sets the Severity of each of r's findings that is SeverityDefault to r.DefaultSeverity.
+func setSeverity(r Rule, findings []Finding) []Finding {
+	for i := range findings {
+		if findings[i].Severity == SeverityDefault {
+			findings[i].Severity = r.DefaultSeverity()
+		}
+	}
+	return findings
+}
+
+// ruleName returns the ID and name of r, such as "gomodvet-003 (multiplemajor)".
+func ruleName(r Rule) string {
+	return fmt.Sprintf("%s (%s)", r.ID(), r.Name())
+}
+
+// addChains sets the Chain of each Finding about a specific module version, using the
+// module requirement graph, which is only loaded if there is such a Finding.
+func addChains(ctx context.Context, s *Snapshot, findings []Finding) error {
+	for i, f := range findings {
+		if f.Path == "" || f.Version == "" {
+			continue
+		}
+		g, err := s.Graph(ctx)
+		if err != nil {
+			return err
+		}
+		for _, n := range g.ShortestPath(g.Main(), modgraph.Node{Path: f.Path, Version: f.Version}) {
+			findings[i].Chain = append(findings[i].Chain, n.String())
+		}
+	}
+	return nil
+}
+
+// add records the findings from running r, or returns a *RuleError if r failed.
+func (res *Result) add(ctx context.Context, r Rule, findings []Finding, err error) error {
+	if err != nil {
+		if ctx.Err() != nil {
+			// report the interruption, rather than however it surfaced from the go command.
+			err = ctx.Err()
+		}
+		return &RuleError{Rule: r, Err: err}
+	}
+	res.Findings = append(res.Findings, res.Config.Apply(r, findings)...)
+	res.Ran[r.ID()] = true
+	return nil
+}
+
+// Stopped reports if res includes a GoModNeedsUpdateID finding,
+// in which case the remaining rules were not run.
+func (res *Result) Stopped() bool {
+	for _, f := range res.Findings {
+		if f.Rule == GoModNeedsUpdateID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package vet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/thepudds/gomodvet/buildlist"
+	"github.com/thepudds/gomodvet/gotool"
+	"github.com/thepudds/gomodvet/modfile"
+	"github.com/thepudds/gomodvet/modgraph"
+)
+
+// Snapshot holds the information about the current module used by the gomodvet rules,
+// such as the build list, the module requirement graph, and parsed 'go.mod' files.
+// Each piece of information is loaded on first use and then cached, so that
+// all rules sharing a Snapshot share a single 'go list -m all', 'go mod graph', etc.
+// A Snapshot is safe for concurrent use by multiple rules, but its exported fields
+// must not be changed after first use.
+type Snapshot struct {
+	Dir      string      // directory within the module, or the empty string for the current working directory
+	Verbose  bool        // verbose: rules show additional information
+	Tool     gotool.Tool // runs the go command; a gotool.Fake allows testing rules without the go command
+	Parallel int         // maximum number of concurrent operations within a rule; zero means GOMAXPROCS
+	Out      io.Writer   // receives diagnostics, such as the additional information shown when Verbose; nil means os.Stdout
+
+	goMod   result
+	build   result
+	upgrade result
+	graph   result
+	pkgs    result
+
+	mu    sync.Mutex // protects files
+	files map[string]*result
+
+	outMu sync.Mutex // serializes writes to Out by concurrent rules
+}
+
+// result is a cached value and error, which is loaded once.
+type result struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// NewSnapshot returns a Snapshot for the module containing dir.
+// The empty string for dir means the current working directory.
+// The go command found in PATH is used, unless the Tool field is changed before first use.
+// Nothing is loaded until first requested.
+func NewSnapshot(dir string, verbose bool) *Snapshot {
+	return &Snapshot{Dir: dir, Verbose: verbose, Tool: gotool.Exec{}, files: make(map[string]*result)}
+}
+
+// load returns the cached result in r, calling f to fill it in if needed.
+// Concurrent callers wait for the first call to f.
+func load(r *result, f func() (interface{}, error)) (interface{}, error) {
+	r.once.Do(func() { r.value, r.err = f() })
+	return r.value, r.err
+}
+
+// logf writes a diagnostic to s.Out as a single write, so that output from
+// concurrent rules is not interleaved.
+func (s *Snapshot) logf(format string, args ...interface{}) {
+	out := s.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	fmt.Fprintf(out, format, args...)
+}
+
+// GoMod returns the path to the main module's 'go.mod' as reported by 'go env GOMOD',
+// or the empty string if there is no current 'go.mod'.
+func (s *Snapshot) GoMod(ctx context.Context) (string, error) {
+	v, err := load(&s.goMod, func() (interface{}, error) {
+		return buildlist.GoModPathWith(ctx, s.Tool, s.Dir)
+	})
+	path, _ := v.(string)
+	return path, err
+}
+
+// BuildList returns the build list, as reported by 'go list -json -m all'.
+func (s *Snapshot) BuildList(ctx context.Context) ([]buildlist.Module, error) {
+	v, err := load(&s.build, func() (interface{}, error) { return s.listModules(ctx, false) })
+	mods, _ := v.([]buildlist.Module)
+	return mods, err
+}
+
+// Upgrades returns the build list including available upgrades,
+// as reported by 'go list -u -json -m all'. This generally requires network access.
+func (s *Snapshot) Upgrades(ctx context.Context) ([]buildlist.Module, error) {
+	v, err := load(&s.upgrade, func() (interface{}, error) { return s.listModules(ctx, true) })
+	mods, _ := v.([]buildlist.Module)
+	return mods, err
+}
+
+func (s *Snapshot) listModules(ctx context.Context, upgrades bool) ([]buildlist.Module, error) {
+	if upgrades {
+		return buildlist.ResolveUpgradesWith(ctx, s.Tool, s.Dir)
+	}
+	return buildlist.ResolveWith(ctx, s.Tool, s.Dir)
+}
+
+// Graph returns the module requirement graph, as reported by 'go mod graph'.
+func (s *Snapshot) Graph(ctx context.Context) (*modgraph.Graph, error) {
+	v, err := load(&s.graph, func() (interface{}, error) { return modgraph.LoadWith(ctx, s.Tool, s.Dir) })
+	g, _ := v.(*modgraph.Graph)
+	return g, err
+}
+
+// IsLibrary reports if the main module has any packages other than commands,
+// and hence might be used as a dependency by other modules.
+func (s *Snapshot) IsLibrary(ctx context.Context) (bool, error) {
+	out, err := s.packages(ctx)
+	if err != nil {
+		return false, err
+	}
+	// each line is "import/path name".
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] != "main" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// packages returns the output of 'go list -mod=readonly' for the main module's packages.
+func (s *Snapshot) packages(ctx context.Context) ([]byte, error) {
+	v, err := load(&s.pkgs, func() (interface{}, error) { return s.Tool.ListPackages(ctx, s.Dir) })
+	out, _ := v.([]byte)
+	return out, err
+}
+
+// ModFile returns the parsed 'go.mod' file at path.
+func (s *Snapshot) ModFile(ctx context.Context, path string) (modfile.File, error) {
+	s.mu.Lock()
+	r, ok := s.files[path]
+	if !ok {
+		r = new(result)
+		s.files[path] = r
+	}
+	s.mu.Unlock()
+	v, err := load(r, func() (interface{}, error) {
+		data, err := s.Tool.ReadModFile(ctx, path)
+		if err != nil {
+			return modfile.File{}, err
+		}
+		return modfile.ParseData(path, data)
+	})
+	file, _ := v.(modfile.File)
+	return file, err
+}
+
+// replacement returns the 'replace' directive in the main module's 'go.mod' that applies to
+// the module version, or nil if there is none. As for the go command, a replacement of the specific
+// version takes precedence over a replacement without a version, which applies to all versions.
+func (s *Snapshot) replacement(ctx context.Context, path, version string) (*modfile.Replace, error) {
+	file, err := s.MainModFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var all *modfile.Replace
+	for i, r := range file.Replace {
+		switch {
+		case r.Old.Path != path:
+		case r.Old.Version == version:
+			return &file.Replace[i], nil
+		case r.Old.Version == "":
+			all = &file.Replace[i]
+		}
+	}
+	return all, nil
+}
+
+// MainModFile returns the parsed 'go.mod' file for the main module.
+func (s *Snapshot) MainModFile(ctx context.Context) (modfile.File, error) {
+	path, err := s.GoMod(ctx)
+	if err != nil {
+		return modfile.File{}, err
+	}
+	return s.ModFile(ctx, path)
+}
@@ -0,0 +1,143 @@
+package vet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/thepudds/gomodvet/modfile"
+)
+
+// UnusedSuppressionID is the ID of the rule that reports unused suppression comments.
+const UnusedSuppressionID = "gomodvet-009"
+
+// ignoreMarker starts a suppression comment.
+const ignoreMarker = "gomodvet:ignore"
+
+// Suppression is a comment on a directive in the main module's 'go.mod' of the form
+//
+//	// gomodvet:ignore <rule>[,<rule>...] [reason]
+//
+// which suppresses findings from those rules for the directive's module.
+// Rules may be given by ID or name. For a requirement that is also marked '// indirect',
+// the suppression follows a semicolon, as in '// indirect; gomodvet:ignore gomodvet-007 reason'.
+type Suppression struct {
+	Rule   string   // rule ID
+	Path   string   // module path of the directive
+	Reason string   // optional free-form reason
+	Pos    Position // position of the directive
+}
+
+// Suppressions returns the suppression comments in f.
+// It returns an error if a suppression comment does not name a known rule.
+func Suppressions(f modfile.File) ([]Suppression, error) {
+	var result []Suppression
+	add := func(path, comment string, pos modfile.Position) error {
+		i := strings.Index(comment, ignoreMarker)
+		if i < 0 {
+			return nil
+		}
+		rest := strings.TrimSpace(comment[i+len(ignoreMarker):])
+		rules, reason := rest, ""
+		if j := strings.IndexAny(rest, " \t"); j >= 0 {
+			rules, reason = rest[:j], strings.TrimSpace(rest[j:])
+		}
+		if rules == "" {
+			return fmt.Errorf("%s: %s comment must name a rule", pos, ignoreMarker)
+		}
+		for _, name := range strings.Split(rules, ",") {
+			r := Lookup(name)
+			if r == nil {
+				return fmt.Errorf("%s: %s comment names unknown rule %q", pos, ignoreMarker, name)
+			}
+			result = append(result, Suppression{Rule: r.ID(), Path: path, Reason: reason, Pos: pos})
+		}
+		return nil
+	}
+
+	if err := add(f.Module.Path, f.Module.Comment, f.Module.Pos); err != nil {
+		return nil, err
+	}
+	for _, req := range f.Require {
+		if err := add(req.Path, req.Comment, req.Pos); err != nil {
+			return nil, err
+		}
+	}
+	for _, exclude := range f.Exclude {
+		if err := add(exclude.Path, exclude.Comment, exclude.Pos); err != nil {
+			return nil, err
+		}
+	}
+	for _, replace := range f.Replace {
+		if err := add(replace.Old.Path, replace.Comment, replace.Pos); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// matches reports if sup applies to f, either because f is about the directive's module,
+// or because f's position is the directive itself.
+func (sup Suppression) matches(f Finding) bool {
+	if f.Rule != sup.Rule {
+		return false
+	}
+	if f.Path != "" && f.Path == sup.Path {
+		return true
+	}
+	return f.Pos.Filename == sup.Pos.Filename && f.Pos.Line == sup.Pos.Line
+}
+
+// Suppress applies the suppression comments in the main module's 'go.mod' to findings,
+// returning findings with the Suppression field set on each suppressed Finding.
+// ran reports which rules ran, keyed by rule ID. If the UnusedSuppressionID rule ran,
+// Suppress also returns a Finding for each suppression of a rule that ran
+// but did not suppress anything.
+func Suppress(ctx context.Context, s *Snapshot, findings []Finding, ran map[string]bool) (result []Finding, unused []Finding, err error) {
+	file, err := s.MainModFile(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	sups, err := Suppressions(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	used := make([]bool, len(sups))
+	for _, f := range findings {
+		for i := range sups {
+			if sups[i].matches(f) {
+				used[i] = true
+				if f.Suppression == nil {
+					sup := sups[i]
+					f.Suppression = &sup
+				}
+			}
+		}
+		result = append(result, f)
+	}
+
+	if !ran[UnusedSuppressionID] {
+		return result, nil, nil
+	}
+	for i, sup := range sups {
+		if used[i] || !ran[sup.Rule] {
+			continue
+		}
+		unused = append(unused, Finding{
+			Rule: UnusedSuppressionID,
+			Path: sup.Path,
+			Message: fmt.Sprintf("unused suppression: '// %s %s' on %s does not suppress any finding",
+				ignoreMarker, sup.Rule, sup.Path),
+			Pos: sup.Pos,
+		})
+	}
+	return result, unused, nil
+}
+
+// UnusedSuppressions is the Run function for the gomodvet-009 rule. It reports nothing itself;
+// instead, Suppress reports unused suppressions once the findings of all other rules are known.
+// Rule: gomodvet-009
+func UnusedSuppressions(ctx context.Context, s *Snapshot) ([]Finding, error) {
+	return nil, nil
+}
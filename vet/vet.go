@@ -4,125 +4,167 @@
 package vet
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
 	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/rogpeppe/go-internal/semver"
-	"github.com/thepudds/gomodvet/buildlist"
 	"github.com/thepudds/gomodvet/modfile"
 	"github.com/thepudds/gomodvet/modgraph"
 )
 
 // GoModNeedsUpdate reports if the current 'go.mod' would be updated by
 // a 'go build', 'go list', or similar command.
+// It returns a Finding if an update is needed.
 // Rule: gomodvet-001.
-func GoModNeedsUpdate(verbose bool) (bool, error) {
-
-	// TODO: better way to check this that is more specific to readonly.
-	// Probably better to check 'go' output for the specific error?
+func GoModNeedsUpdate(ctx context.Context, s *Snapshot) ([]Finding, error) {
 
 	// Note that 'go list -mod=readonly -m all' does not complain if an update is needed,
-	// but 'go list -mod=readonly' does complain.
-	out, err := exec.Command("go", "list", "-mod=readonly", "./...").CombinedOutput()
+	// but 'go list -mod=readonly' does complain. Rather than running 'go list' again without
+	// -mod=readonly, which would update 'go.mod', we check the go command's error.
+	_, err := s.packages(ctx)
 	if err != nil {
-		if verbose {
-			fmt.Println("gomodvet: error reported when running 'go list -mod=readonly':", string(out))
+		if ctx.Err() != nil {
+			// canceled or timed out, rather than a problem with 'go.mod'.
+			return nil, ctx.Err()
+		}
+		if !needsUpdate(err) {
+			// an error unrelated to whether or not an update is needed.
+			s.logf("gomodvet: error reported when running 'go list -mod=readonly': %v\n", err)
+			return nil, err
+		}
+		if s.Verbose {
+			s.logf("gomodvet: error reported when running 'go list -mod=readonly': %v\n", err)
 		}
+		return []Finding{{
+			Rule:    "gomodvet-001",
+			Message: "the current module's 'go.mod' file would be updated by a 'go build' or 'go list. Please update prior to using gomodvet.",
+		}}, nil
+	}
+	return nil, nil
+}
 
-		out2, err2 := exec.Command("go", "list", "./...").CombinedOutput()
-		if err2 != nil {
-			// error with -mod=readonly, but also without -mod=readonly, so this is likely an error
-			// unrelated to whether or not an update is needed.
-			fmt.Println("gomodvet: error reported when running 'go list':", string(out2))
-			return false, err2
+// needsUpdateErrors are fragments of the errors reported by 'go list -mod=readonly' when
+// 'go.mod' or 'go.sum' needs an update, for current and earlier versions of the go command.
+var needsUpdateErrors = []string{
+	"updates to go.mod needed",
+	"import lookup disabled by -mod=readonly",
+	"no required module provides package",
+	"missing go.sum entry",
+}
+
+// needsUpdate reports if err from 'go list -mod=readonly' is due to 'go.mod' or 'go.sum' needing an update.
+func needsUpdate(err error) bool {
+	for _, msg := range needsUpdateErrors {
+		if strings.Contains(err.Error(), msg) {
+			return true
 		}
-		// // error with -mod=readonly, but not without -mod=readonly, so likely due to the -mod=readonly
-		fmt.Println("gomodvet-001: the current module's 'go.mod' file would be updated by a 'go build' or 'go list. Please update prior to using gomodvet.")
-		return true, nil
 	}
-	return false, nil
+	return false
 }
 
 // Upgrades reports if the are any upgrades for any direct and indirect dependencies.
-// It returns true if upgrades are needed.
+// It returns a Finding for each module with an available upgrade.
 // Rule: gomodvet-002
-func Upgrades(verbose bool) (bool, error) {
-	mods, err := buildlist.ResolveUpgrades()
+func Upgrades(ctx context.Context, s *Snapshot) ([]Finding, error) {
+	mods, err := s.Upgrades(ctx)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := requirePositions(ctx, s)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	flagged := false
+	var findings []Finding
 	for _, mod := range mods {
-		if verbose {
-			fmt.Printf("gomodvet: upgrades: module %s: %+v\n", mod.Path, mod)
+		if s.Verbose {
+			s.logf("gomodvet: upgrades: module %s: %+v\n", mod.Path, mod)
 		}
 		if mod.Update != nil {
-			fmt.Println("gomodvet-002: dependencies have available updates: ", mod.Path, mod.Update.Version)
-			flagged = true
+			findings = append(findings, Finding{
+				Rule:    "gomodvet-002",
+				Path:    mod.Path,
+				Version: mod.Version,
+				Message: fmt.Sprintf("dependencies have available updates: %s %s", mod.Path, mod.Update.Version),
+				Related: []string{mod.Path + "@" + mod.Update.Version},
+				Pos:     positions[mod.Path],
+			})
 		}
 	}
-	return flagged, nil
+	return findings, nil
 }
 
 // MultipleMajor reports if the current module has any dependencies with multiple major versions.
 // For example, if the current module is 'foo', it reports if there is a 'bar' and 'bar/v3' as dependencies of 'foo'.
-// It returns true if multiple major versions are found.
+// It returns a Finding for each additional major version found.
 // Note that this looks for Semantic Import Version '/vN' versions, not gopkg.in versions. (Probably reasonable to not flag gopkg.in?)
 // Could use SplitPathVersion from https://github.com/rogpeppe/go-internal/blob/master/module/module.go#L274
 // Rule: gomodvet-003
-func MultipleMajor(verbose bool) (bool, error) {
+func MultipleMajor(ctx context.Context, s *Snapshot) ([]Finding, error) {
 	// TODO: non-regexp parsing of '/vN'?
 	re := regexp.MustCompile("/v[0-9]+$")
 	// track our paths in { strippedPath: fullPath, ... } map.
 	paths := make(map[string]string)
-	mods, err := buildlist.Resolve()
+	mods, err := s.BuildList(ctx)
+	if err != nil {
+		s.logf("gomodvet: %v\n", err)
+		return nil, err
+	}
+	positions, err := requirePositions(ctx, s)
 	if err != nil {
-		fmt.Println("gomodvet:", err)
-		return false, err
+		return nil, err
 	}
 
-	flagged := false
+	var findings []Finding
 	for _, mod := range mods {
-		if verbose {
-			fmt.Printf("gomodvet: multiplemajors: module %s: %+v\n", mod.Path, mod)
+		if s.Verbose {
+			s.logf("gomodvet: multiplemajors: module %s: %+v\n", mod.Path, mod)
 		}
 		strippedPath := re.ReplaceAllString(mod.Path, "")
 		if priorPath, ok := paths[strippedPath]; ok {
-			fmt.Println("gomodvet-003: a module has multiple major versions in this build: ", priorPath, mod.Path)
-			flagged = true
+			findings = append(findings, Finding{
+				Rule:    "gomodvet-003",
+				Path:    mod.Path,
+				Version: mod.Version,
+				Message: fmt.Sprintf("a module has multiple major versions in this build: %s %s", priorPath, mod.Path),
+				Related: []string{priorPath},
+				Pos:     positions[mod.Path],
+			})
 		}
 		paths[strippedPath] = mod.Path
 	}
-	return flagged, nil
+	return findings, nil
 }
 
 // ConflictingRequires reports if the current module or any dependencies have:
 //    -- different v0 versions of a shared dependency.
 //    -- a v0 version of a shared dependency plus a v1 version.
 //    -- a vN+incompatible (N > 2) version of a shared dependency plus a v0, v1, or other vN+incompatible.
-// It returns true if so.
+// It returns a Finding for each module path with potentially incompatible versions.
 // Rule: gomodvet-004
-func ConflictingRequires(verbose bool) (bool, error) {
-	// obtain the set of requires by all modules in our build (via 'go mod graph').
+func ConflictingRequires(ctx context.Context, s *Snapshot) ([]Finding, error) {
+	// obtain the requirements of all modules in our build (via 'go mod graph').
 	// this takes into account replace directives.
-	requires, err := modgraph.Requirements()
+	g, err := s.Graph(ctx)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	positions, err := requirePositions(ctx, s)
+	if err != nil {
+		return nil, err
 	}
 
 	// track our paths and versions in { path: {version, version, ...}, ... } map.
 	paths := make(map[string][]string)
-	for _, require := range requires {
-		f := strings.Split(require, "@")
-		if len(f) != 2 {
-			return false, fmt.Errorf("unexpected requirement: %s", require)
+	for _, e := range g.Edges() {
+		path, version := e.To.Path, e.To.Version
+		if version == "" {
+			return nil, fmt.Errorf("unexpected requirement: %s", e.To)
 		}
-		path, version := f[0], f[1]
 		if !semver.IsValid(version) {
-			return false, fmt.Errorf("invalid semver version: %s", require)
+			return nil, fmt.Errorf("invalid semver version: %s", e.To)
 		}
 
 		// Probably not needed, but might as well use the canonical semver version. That strips "+incompatible",
@@ -134,14 +176,21 @@ func ConflictingRequires(verbose bool) (bool, error) {
 		}
 	}
 
-	// for each path, loop over its versions (in semantic order) and build up a list
-	// of potential conflicts.
-	flagged := false
-	for path, versions := range paths {
+	// for each path (in sorted order, for stable results), loop over its versions
+	// (in semantic order) and build up a list of potential conflicts.
+	sortedPaths := make([]string, 0, len(paths))
+	for path := range paths {
+		sortedPaths = append(sortedPaths, path)
+	}
+	sort.Strings(sortedPaths)
+
+	var findings []Finding
+	for _, path := range sortedPaths {
+		versions := paths[path]
 		sort.Slice(versions, func(i, j int) bool { return -1 == semver.Compare(versions[i], versions[j]) })
 
-		if verbose {
-			fmt.Printf("gomodvet: conflictingrequires: module %q has require versions: %v\n", path, versions)
+		if s.Verbose {
+			s.logf("gomodvet: conflictingrequires: module %q has require versions: %v\n", path, versions)
 		}
 
 		priorVersion := ""
@@ -165,16 +214,45 @@ func ConflictingRequires(verbose bool) (bool, error) {
 		}
 		if len(potentialIncompats) > 1 {
 			// mutiple potential incompatible versions, which means they can be incompatible with each other.
-			fmt.Printf("gomodvet-004: module %q was required with potentially incompatible versions: %s\n",
-				path, strings.Join(potentialIncompats, ", "))
-			flagged = true
+			var related []string
+			for _, version := range potentialIncompats {
+				related = append(related, path+"@"+version)
+			}
+			if s.Verbose {
+				for _, version := range potentialIncompats {
+					s.logf("gomodvet: conflictingrequires: %s@%s is required by: %v\n", path, version, requirers(g, path, version))
+				}
+			}
+			findings = append(findings, Finding{
+				Rule: "gomodvet-004",
+				Path: path,
+				Message: fmt.Sprintf("module %q was required with potentially incompatible versions: %s",
+					path, strings.Join(potentialIncompats, ", ")),
+				Related: related,
+				Pos:     positions[path],
+			})
 		}
 	}
-	return flagged, nil
+	return findings, nil
+}
+
+// requirers returns the module versions that require path at version in g,
+// matching version with or without a "+incompatible" suffix.
+func requirers(g *modgraph.Graph, path, version string) []string {
+	var result []string
+	for _, n := range g.Lookup(path) {
+		if semver.Compare(n.Version, version) != 0 {
+			continue
+		}
+		for _, p := range g.Predecessors(n) {
+			result = append(result, p.String())
+		}
+	}
+	return result
 }
 
 // ExcludedVersion reports if the current module or any dependencies are using a version excluded by a dependency.
-// It returns true if so.
+// It returns a Finding for each excluded version in use.
 // Currently requires main module's go.mod being in a consistent state (e.g., after a 'go list' or 'go build'), such that
 // the main module does not have a go.mod file using something it excludes.
 // gomodvet enforces this requirement.
@@ -184,132 +262,205 @@ func ConflictingRequires(verbose bool) (bool, error) {
 // but a person could check in any given 'go.mod' file prior to letting the 'go' tool use canonical version strings. If
 // that were to happen, the current ExcludedVersion could have a false negative (that is, potentially miss flagging something).
 // Rule: gomodvet-005
-func ExcludedVersion(verbose bool) (bool, error) {
+func ExcludedVersion(ctx context.Context, s *Snapshot) ([]Finding, error) {
 	report := func(err error) error { return fmt.Errorf("excludedversion: %v", err) }
 
 	// track our versions in { path: version } map.
 	versions := make(map[string]string)
-	mods, err := buildlist.Resolve()
+	mods, err := s.BuildList(ctx)
 	if err != nil {
-		return false, report(err)
+		return nil, report(err)
 	}
 	// build up our reference map
 	for _, mod := range mods {
-		if verbose {
-			fmt.Printf("gomodvet: excludedversion: module %s: %+v\n", mod.Path, mod)
+		if s.Verbose {
+			s.logf("gomodvet: excludedversion: module %s: %+v\n", mod.Path, mod)
 		}
 		versions[mod.Path] = mod.Version
 	}
 
-	// do our check by parsing each 'go.mod' file being used,
+	// parse each 'go.mod' file being used, in parallel given there can be many.
+	files := make([]modfile.File, len(mods))
+	errs := make([]error, len(mods))
+	forEach(len(mods), s.Parallel, func(i int) {
+		if !mods[i].Main {
+			files[i], errs[i] = s.ModFile(ctx, mods[i].GoMod)
+		}
+	})
+
+	// do our check using each parsed 'go.mod' file,
 	// and check if we are using a path/version combination excluded
 	// by one of a go.mod file in our dependecies
-	flagged := false
-	for _, mod := range mods {
+	var findings []Finding
+	for i, mod := range mods {
 		if mod.Main {
 			// here we assume the main module's 'go.mod' is in a consistent state,
 			// and not using something excluded in its own 'go.mod' file. The 'go' tool
 			// enforces this on a 'go build', 'go mod tidy', etc.
 			continue
 		}
-		file, err := modfile.Parse(mod.GoMod)
-		if err != nil {
-			return false, report(err)
+		if errs[i] != nil {
+			return nil, report(errs[i])
 		}
-		for _, exclude := range file.Exclude {
+		for _, exclude := range files[i].Exclude {
 			usingVersion, ok := versions[exclude.Path]
 			if !ok {
 				continue
 			}
 			if usingVersion == exclude.Version {
-				fmt.Printf("gomodvet-005: a module is using a version excluded by another module. excluded version: %s %s\n",
-					exclude.Path, exclude.Version)
-				flagged = true
+				findings = append(findings, Finding{
+					Rule:    "gomodvet-005",
+					Path:    exclude.Path,
+					Version: exclude.Version,
+					Message: fmt.Sprintf("a module is using a version excluded by another module. excluded version: %s %s",
+						exclude.Path, exclude.Version),
+					Related: []string{mod.Path + "@" + mod.Version},
+					Pos:     exclude.Pos,
+				})
 			}
 		}
 	}
-	return flagged, nil
+	return findings, nil
 }
 
 // Prerelease reports if the current module or any dependencies are using a prerelease semver version
 // (exclusive of pseudo-versions, which are also prerelease versions according to semver spec but are reported separately).
-// It returns true if so.
+// It returns a Finding for each module using a prerelease version.
 // Rule: gomodvet-006
-func Prerelease(verbose bool) (bool, error) {
-	mods, err := buildlist.Resolve()
+func Prerelease(ctx context.Context, s *Snapshot) ([]Finding, error) {
+	mods, err := s.BuildList(ctx)
 	if err != nil {
-		return false, fmt.Errorf("prerelease: %v", err)
+		return nil, fmt.Errorf("prerelease: %v", err)
+	}
+	positions, err := requirePositions(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("prerelease: %v", err)
 	}
 
-	flagged := false
+	var findings []Finding
 	for _, mod := range mods {
-		if verbose {
-			fmt.Printf("gomodvet: prerelease: module %s: %+v\n", mod.Path, mod)
+		if s.Verbose {
+			s.logf("gomodvet: prerelease: module %s: %+v\n", mod.Path, mod)
 		}
 		if isPrerelease(mod.Version) {
-			fmt.Printf("gomodvet-006: a module is using a prerelease version: %s %s\n",
-				mod.Path, mod.Version)
-			flagged = true
+			findings = append(findings, Finding{
+				Rule:    "gomodvet-006",
+				Path:    mod.Path,
+				Version: mod.Version,
+				Message: fmt.Sprintf("a module is using a prerelease version: %s %s", mod.Path, mod.Version),
+				Pos:     positions[mod.Path],
+			})
 		}
 	}
-	return flagged, nil
+	return findings, nil
 }
 
 // PseudoVersion reports if the current module or any dependencies are using a prerelease semver version
 // (exclusive of pseudo-versions, which are also prerelease versions according to semver spec but are reported separately).
-// It returns true if so.
+// It returns a Finding for each module using a pseudo-version.
 // Rule: gomodvet-007
-func PseudoVersion(verbose bool) (bool, error) {
-	mods, err := buildlist.Resolve()
+func PseudoVersion(ctx context.Context, s *Snapshot) ([]Finding, error) {
+	mods, err := s.BuildList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pseudoversion: %v", err)
+	}
+	positions, err := requirePositions(ctx, s)
 	if err != nil {
-		return false, fmt.Errorf("pseudoversion: %v", err)
+		return nil, fmt.Errorf("pseudoversion: %v", err)
 	}
 
-	flagged := false
+	var findings []Finding
 	for _, mod := range mods {
-		if verbose {
-			fmt.Printf("gomodvet: pseudoversion: module %s: %+v\n", mod.Path, mod)
+		if s.Verbose {
+			s.logf("gomodvet: pseudoversion: module %s: %+v\n", mod.Path, mod)
 		}
 		if isPseudoVersion(mod.Version) {
-			fmt.Printf("gomodvet-007: a module is using a pseudoversion version: %s %s\n",
-				mod.Path, mod.Version)
-			flagged = true
+			findings = append(findings, Finding{
+				Rule:    "gomodvet-007",
+				Path:    mod.Path,
+				Version: mod.Version,
+				Message: fmt.Sprintf("a module is using a pseudoversion version: %s %s", mod.Path, mod.Version),
+				Pos:     positions[mod.Path],
+			})
 		}
 	}
-	return flagged, nil
+	return findings, nil
 }
 
 // Replace reports if the current go.mod has 'replace' directives.
-// It returns true if so.
+// It returns a Finding for each 'replace' directive, which is an error if the main module
+// is a library and otherwise a warning.
 // The parses the 'go.mod' for the main module, and hence can report
 // true if the main module's 'go.mod' has ineffective replace directives.
 // Part of the use case is some people never want to check in a replace directive,
 // and this can be used to check that.
 // Rule: gomodvet-008
-func Replace(verbose bool) (bool, error) {
-	mods, err := buildlist.Resolve()
+func Replace(ctx context.Context, s *Snapshot) ([]Finding, error) {
+	mods, err := s.BuildList(ctx)
 	if err != nil {
-		return false, fmt.Errorf("replace: %v", err)
+		return nil, fmt.Errorf("replace: %v", err)
 	}
 
-	flagged := false
+	// a library's 'replace' directives are ignored when it is used as a dependency,
+	// which is more likely to be a problem than for a module that only has commands,
+	// so a module with only commands gets a warning rather than the rule's default error.
+	library, err := s.IsLibrary(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("replace: %v", err)
+	}
+	severity := SeverityDefault
+	if !library {
+		severity = SeverityWarning
+	}
+
+	var findings []Finding
 	for _, mod := range mods {
 		if !mod.Main {
 			continue
 		}
-		if verbose {
-			fmt.Printf("gomodvet: replacement: module %s: %+v\n", mod.Path, mod)
+		if s.Verbose {
+			s.logf("gomodvet: replacement: module %s: %+v\n", mod.Path, mod)
 		}
-		file, err := modfile.Parse(mod.GoMod)
+		file, err := s.ModFile(ctx, mod.GoMod)
 		if err != nil {
-			return false, fmt.Errorf("replace: %v", err)
+			return nil, fmt.Errorf("replace: %v", err)
 		}
-		if len(file.Replace) > 0 {
-			fmt.Printf("gomodvet-008: the main module has 'replace' directives\n")
-			flagged = true
+		for _, replace := range file.Replace {
+			findings = append(findings, Finding{
+				Rule:     "gomodvet-008",
+				Severity: severity,
+				Path:     replace.Old.Path,
+				Version:  replace.Old.Version,
+				Message: fmt.Sprintf("the main module has a 'replace' directive: %s => %s",
+					replace.Old, replace.New),
+				Related: []string{replace.New.String()},
+				Pos:     replace.Pos,
+			})
 		}
 	}
-	return flagged, nil
+	return findings, nil
+}
+
+// requirePositions returns the positions of the 'require' directives
+// in the main module's 'go.mod', keyed by module path.
+// Modules not directly required by the main module have no entry.
+func requirePositions(ctx context.Context, s *Snapshot) (map[string]Position, error) {
+	goMod, err := s.GoMod(ctx)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[string]Position)
+	if goMod == "" {
+		return positions, nil
+	}
+	file, err := s.ModFile(ctx, goMod)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range file.Require {
+		positions[req.Path] = req.Pos
+	}
+	return positions, nil
 }
 
 func isPseudoVersion(version string) bool {
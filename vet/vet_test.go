@@ -0,0 +1,438 @@
+package vet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rogpeppe/go-internal/txtar"
+	"github.com/thepudds/gomodvet/gotool"
+)
+
+// The tests here run rules against canned go command output via a gotool.Fake.
+// Each testdata/rules/<rule name>[-<case>].txtar file holds the canned output for the rule
+// (see gotool.Fake), along with a "want" file listing the expected findings (see findingString)
+// or a "want-error" file holding a substring of the expected error.
+// Each testdata/run/*.txtar file is the same, but for running all default-enabled rules via Run,
+// with Options.Offline set if the archive holds an "offline" file.
+// The testdata/why/*.txtar files are canned output for specific cases of Why.
+
+func TestRules(t *testing.T) {
+	files, err := filepath.Glob("testdata/rules/*.txtar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".txtar")
+		t.Run(name, func(t *testing.T) {
+			r := Lookup(strings.Split(name, "-")[0])
+			if r == nil {
+				t.Fatalf("no rule for %s", file)
+			}
+			s, a := fakeSnapshot(t, file)
+			findings, err := runRule(context.Background(), r, s, nil)
+			check(t, a, findings, err)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	files, err := filepath.Glob("testdata/run/*.txtar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".txtar"), func(t *testing.T) {
+			tool, a := loadFake(t, file)
+			opts := Options{Tool: tool, Config: &Config{}}
+			for _, f := range a.Files {
+				opts.Offline = opts.Offline || f.Name == "offline"
+			}
+			res, err := Run(context.Background(), opts)
+			var findings []Finding
+			if res != nil {
+				findings = res.Findings
+			}
+			check(t, a, findings, err)
+			if opts.Offline && res != nil && res.Skipped["gomodvet-002"] != SkippedOffline {
+				t.Errorf("got skipped %v, want gomodvet-002 skipped %q", res.Skipped, SkippedOffline)
+			}
+		})
+	}
+}
+
+// TestCache checks that a second Run of an unchanged module uses the cached build list,
+// requirement graph, and findings, rather than running the go command again.
+func TestCache(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir()}
+	tool, a := loadFake(t, "testdata/run/suppression.txtar")
+	first, err := Run(context.Background(), Options{Tool: tool, Config: &Config{}, Cache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Cached) != 0 {
+		t.Errorf("first run: got cached rules %v, want none", first.Cached)
+	}
+
+	// the go commands for the cached information now fail.
+	for i, f := range a.Files {
+		switch f.Name {
+		case "list-m.json", "graph.txt":
+			a.Files[i].Name = gotool.ErrName(f.Name)
+		}
+	}
+	second, err := Run(context.Background(), Options{Tool: gotool.NewFake("cached", a), Config: &Config{}, Cache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"gomodvet-003", "gomodvet-004", "gomodvet-005", "gomodvet-006", "gomodvet-007"} {
+		if !second.Cached[id] {
+			t.Errorf("second run: %s not cached", id)
+		}
+	}
+	check(t, a, second.Findings, nil)
+}
+
+// fakeSnapshot returns a Snapshot backed by the gotool.Fake for the txtar archive file,
+// along with the archive.
+func fakeSnapshot(t *testing.T, file string) (*Snapshot, *txtar.Archive) {
+	t.Helper()
+	tool, a := loadFake(t, file)
+	s := NewSnapshot("", false)
+	s.Tool = tool
+	return s, a
+}
+
+func loadFake(t *testing.T, file string) (*gotool.Fake, *txtar.Archive) {
+	t.Helper()
+	a, err := txtar.ParseFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gotool.NewFake(file, a), a
+}
+
+// check compares findings and err to the "want" or "want-error" file in a.
+func check(t *testing.T, a *txtar.Archive, findings []Finding, err error) {
+	t.Helper()
+	var want, wantErr string
+	for _, f := range a.Files {
+		switch f.Name {
+		case "want":
+			want = string(f.Data)
+		case "want-error":
+			wantErr = strings.TrimSpace(string(f.Data))
+		}
+	}
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("got error %v, want error containing %q", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	for _, f := range findings {
+		got += findingString(f) + "\n"
+	}
+	if got != want {
+		t.Errorf("got findings:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// findingString returns f in the form "severity: rule: message @ position (via chain) (suppressed: reason)",
+// omitting the position and suppression if not set, and the chain if it is for a direct dependency.
+func findingString(f Finding) string {
+	s := fmt.Sprintf("%s: %s", f.Severity, f)
+	if f.Pos.IsValid() {
+		s += " @ " + f.Pos.String()
+	}
+	if len(f.Chain) > 2 {
+		s += " (via " + strings.Join(f.Chain, " -> ") + ")"
+	}
+	if f.Suppression != nil {
+		s += " (suppressed: " + f.Suppression.Reason + ")"
+	}
+	return s
+}
+
+func TestWhy(t *testing.T) {
+	s, _ := fakeSnapshot(t, "testdata/run/chain.txtar")
+	e, err := Why(context.Background(), s, "example.com/c", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Requirement{
+		{By: "example.com/a@v1.0.0", Version: "v0.1.0"},
+		{By: "example.com/b@v1.1.0", Version: "v0.2.0-rc.1", Selected: true},
+	}
+	if fmt.Sprint(e.Requirements) != fmt.Sprint(want) {
+		t.Errorf("got requirements %v, want %v", e.Requirements, want)
+	}
+	if got, want := e.Reason(), "v0.2.0-rc.1 is the highest required version"; got != want {
+		t.Errorf("got reason %q, want %q", got, want)
+	}
+	if got, want := strings.Join(e.Chain, " -> "), "example.com/hello -> example.com/b@v1.1.0 -> example.com/c@v0.2.0-rc.1"; got != want {
+		t.Errorf("got chain %q, want %q", got, want)
+	}
+	if !e.UpgradesChecked || e.Update != "" {
+		t.Errorf("got update %q (checked %v), want none (checked)", e.Update, e.UpgradesChecked)
+	}
+
+	if _, err := Why(context.Background(), s, "example.com/missing", false); err == nil {
+		t.Errorf("got no error for a module not in the build list")
+	} else if _, ok := err.(*UsageError); !ok {
+		t.Errorf("got error %T, want *UsageError", err)
+	}
+}
+
+func TestExportGraph(t *testing.T) {
+	tests := []struct {
+		opts  GraphOptions
+		nodes string // each node, followed by its (labels), * if selected, and ! if highlighted
+		edges string // each highlighted edge
+	}{
+		{
+			GraphOptions{},
+			"example.com/hello* example.com/a@v1.0.0* example.com/b@v1.1.0* example.com/c@v0.1.0(v0) " +
+				"example.com/c@v0.2.0-rc.1(prerelease,v0)* example.com/d@v0.0.0-20190110200230-915654e7eabc(pseudo-version,v0)*",
+			"",
+		},
+		{
+			GraphOptions{BuildList: true, Highlight: "example.com/d"},
+			"example.com/hello*! example.com/a@v1.0.0*! example.com/b@v1.1.0*! example.com/c@v0.2.0-rc.1(prerelease,v0)*! " +
+				"example.com/d@v0.0.0-20190110200230-915654e7eabc(pseudo-version,v0)*!",
+			// a's requirement on c v0.1.0 is collapsed to the selected c v0.2.0-rc.1.
+			"example.com/hello->example.com/a@v1.0.0 example.com/hello->example.com/b@v1.1.0 example.com/a@v1.0.0->example.com/c@v0.2.0-rc.1 " +
+				"example.com/b@v1.1.0->example.com/c@v0.2.0-rc.1 example.com/c@v0.2.0-rc.1->example.com/d@v0.0.0-20190110200230-915654e7eabc",
+		},
+		{
+			GraphOptions{Highlight: "example.com/c"},
+			"example.com/hello*! example.com/a@v1.0.0*! example.com/b@v1.1.0*! example.com/c@v0.1.0(v0)! " +
+				"example.com/c@v0.2.0-rc.1(prerelease,v0)*! example.com/d@v0.0.0-20190110200230-915654e7eabc(pseudo-version,v0)*",
+			"example.com/hello->example.com/a@v1.0.0 example.com/hello->example.com/b@v1.1.0 example.com/a@v1.0.0->example.com/c@v0.1.0 " +
+				"example.com/b@v1.1.0->example.com/c@v0.2.0-rc.1",
+		},
+	}
+	for _, tt := range tests {
+		s, _ := fakeSnapshot(t, "testdata/run/chain.txtar")
+		g, err := ExportGraph(context.Background(), s, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var nodes, edges []string
+		for _, n := range g.Nodes {
+			node := n.String()
+			if len(n.Labels) > 0 {
+				node += "(" + strings.Join(n.Labels, ",") + ")"
+			}
+			if n.Selected {
+				node += "*"
+			}
+			if n.Highlighted {
+				node += "!"
+			}
+			nodes = append(nodes, node)
+		}
+		for _, e := range g.Edges {
+			if e.Highlighted {
+				edges = append(edges, e.From.String()+"->"+e.To.String())
+			}
+		}
+		if got := strings.Join(nodes, " "); got != tt.nodes {
+			t.Errorf("%+v: got nodes\n%s\nwant\n%s", tt.opts, got, tt.nodes)
+		}
+		if got := strings.Join(edges, " "); got != tt.edges {
+			t.Errorf("%+v: got highlighted edges\n%s\nwant\n%s", tt.opts, got, tt.edges)
+		}
+	}
+}
+
+// TestExportGraphNoRequirements checks that the main module is exported
+// even if it has no requirements, and hence no edges.
+func TestExportGraphNoRequirements(t *testing.T) {
+	a := txtar.Parse([]byte(`
+-- go.mod --
+module example.com/hello
+-- list-m.json --
+{"Path": "example.com/hello", "Main": true, "GoMod": "go.mod"}
+-- graph.txt --
+`))
+	s := NewSnapshot("", false)
+	s.Tool = gotool.NewFake("norequirements", a)
+	g, err := ExportGraph(context.Background(), s, GraphOptions{BuildList: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 1 || g.Nodes[0].Path != "example.com/hello" || !g.Nodes[0].Main || len(g.Edges) != 0 {
+		t.Errorf("got nodes %+v and edges %+v, want only the main module", g.Nodes, g.Edges)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	s, _ := fakeSnapshot(t, "testdata/run/chain.txtar")
+	ms, err := Metrics(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range ms {
+		got = append(got, fmt.Sprintf("%s direct=%v depth=%d in=%d out=%d exclusive=%d size=%d",
+			m.Path, m.Direct, m.Depth, m.FanIn, m.FanOut, m.Exclusive, m.Size))
+	}
+	// c is required by both a and b, so neither has any exclusive dependencies, but c has d.
+	want := []string{
+		"example.com/a direct=true depth=1 in=1 out=1 exclusive=0 size=0",
+		"example.com/b direct=true depth=1 in=1 out=1 exclusive=0 size=0",
+		"example.com/c direct=false depth=2 in=2 out=1 exclusive=1 size=0",
+		"example.com/d direct=false depth=3 in=1 out=0 exclusive=0 size=0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got metrics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestVerboseOut checks that verbose output goes to Options.Out rather than stdout,
+// which may be reserved for a JSON document.
+func TestVerboseOut(t *testing.T) {
+	tool, _ := loadFake(t, "testdata/run/chain.txtar")
+	var out bytes.Buffer
+	if _, err := Run(context.Background(), Options{Tool: tool, Config: &Config{}, Verbose: true, Out: &out}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "gomodvet: prerelease: module example.com/c") {
+		t.Errorf("got verbose output:\n%s\nwant prerelease details", out.String())
+	}
+}
+
+// TestCacheKey checks that the cache key changes with the 'go.mod' of a directory replacement,
+// and with the files of a workspace, and not with unrelated files.
+func TestCacheKey(t *testing.T) {
+	const archive = `
+-- env.txt --
+GOWORK=ws/go.work
+-- go.mod --
+module example.com/hello
+
+replace example.com/sub => ./sub
+-- sub/go.mod --
+module example.com/sub
+
+require gopkg.in/check.v1 v0.0.0-20161208181325-20d25e280405
+-- ws/go.work --
+go 1.21
+
+use ./w
+-- ws/w/go.mod --
+module example.com/w
+-- unrelated/go.mod --
+module example.com/unrelated
+`
+	key := func(change string) string {
+		a := txtar.Parse([]byte(archive))
+		for i, f := range a.Files {
+			if f.Name == change {
+				a.Files[i].Data = append(f.Data, "// changed\n"...)
+			}
+		}
+		s := NewSnapshot("", false)
+		s.Tool = gotool.NewFake("key", a)
+		k, err := (&Cache{}).key(context.Background(), s, "go.mod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	orig := key("")
+	for _, name := range []string{"go.mod", "sub/go.mod", "ws/go.work", "ws/w/go.mod"} {
+		if key(name) == orig {
+			t.Errorf("changing %s did not change the cache key", name)
+		}
+	}
+	if key("unrelated/go.mod") != orig {
+		t.Errorf("changing an unrelated file changed the cache key")
+	}
+}
+
+// TestCacheTrim checks that old and least recently used entries are removed.
+func TestCacheTrim(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir()}
+	now := time.Now()
+	// entry i was last used i hours ago, with one old entry and one leftover temporary file.
+	for i := 0; i < cacheMaxEntries+2; i++ {
+		name := filepath.Join(cache.Dir, fmt.Sprintf("%03d.json", i))
+		if i == 0 {
+			name = filepath.Join(cache.Dir, "old.json")
+		}
+		if err := ioutil.WriteFile(name, []byte("{}"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		used := now.Add(-time.Duration(i) * time.Hour)
+		if i == 0 {
+			used = now.Add(-cacheMaxAge - time.Hour)
+		}
+		os.Chtimes(name, used, used)
+	}
+	tmp := filepath.Join(cache.Dir, "x.123.tmp")
+	ioutil.WriteFile(tmp, nil, 0666)
+	os.Chtimes(tmp, now.Add(-cacheMaxAge-time.Hour), now.Add(-cacheMaxAge-time.Hour))
+
+	if err := cache.trim(now); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := ioutil.ReadDir(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != cacheMaxEntries {
+		t.Errorf("got %d files after trim, want %d", len(infos), cacheMaxEntries)
+	}
+	for _, name := range []string{"old.json", "x.123.tmp", fmt.Sprintf("%03d.json", cacheMaxEntries+1)} {
+		if _, err := os.Stat(filepath.Join(cache.Dir, name)); err == nil {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+}
+
+// TestWhyUpgradesFailed checks that Why still explains the selected version
+// if checking for updates fails, such as without network access.
+func TestWhyUpgradesFailed(t *testing.T) {
+	_, a := loadFake(t, "testdata/run/chain.txtar")
+	for i, f := range a.Files {
+		if f.Name == "list-u-m.json" {
+			a.Files[i].Name = gotool.ErrName(f.Name)
+		}
+	}
+	s := NewSnapshot("", false)
+	s.Tool = gotool.NewFake("no-network", a)
+	s.Out = ioutil.Discard
+	e, err := Why(context.Background(), s, "example.com/c", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.UpgradesChecked || e.UpgradesError == "" {
+		t.Errorf("got upgrades checked %v with error %q, want unchecked with an error", e.UpgradesChecked, e.UpgradesError)
+	}
+	if e.Selected != "v0.2.0-rc.1" {
+		t.Errorf("got selected %q, want v0.2.0-rc.1", e.Selected)
+	}
+}
+
+func TestWhyReplace(t *testing.T) {
+	s, _ := fakeSnapshot(t, "testdata/why/replace.txtar")
+	e, err := Why(context.Background(), s, "example.com/a", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Replace == nil || e.Replace.New.Path != "example.com/fork" {
+		t.Errorf("got replacement %+v, want the replacement of v1.0.0 by example.com/fork", e.Replace)
+	}
+}
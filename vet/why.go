@@ -0,0 +1,162 @@
+package vet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/rogpeppe/go-internal/semver"
+	"github.com/thepudds/gomodvet/modfile"
+	"github.com/thepudds/gomodvet/modgraph"
+)
+
+// Explanation describes how minimal version selection (MVS) chose the version of a module
+// in the build list, as reported by Why. Its JSON form follows the style of 'go list -json -m'.
+type Explanation struct {
+	Path     string
+	Selected string `json:",omitempty"` // the version in the build list; empty for the main module
+	Main     bool   `json:",omitempty"` // the module is the main module
+	Indirect bool   `json:",omitempty"` // the module is only an indirect dependency of the main module
+
+	// Requirements lists every requirement on the module in the requirement graph,
+	// sorted by version and then by requiring module.
+	Requirements []Requirement
+
+	// Replace is the 'replace' directive in the main module's 'go.mod' that applies to the module, if any.
+	Replace *modfile.Replace `json:",omitempty"`
+
+	// Excludes lists the 'exclude' directives for the module in the main module's 'go.mod'.
+	// Only the main module's 'exclude' directives affect the build.
+	Excludes []modfile.Module `json:",omitempty"`
+
+	// Update is the newest version available, if newer than Selected.
+	// It is only set if upgrades were checked, in which case UpgradesChecked is set.
+	// If checking failed, such as without network access, UpgradesError is set instead.
+	Update          string `json:",omitempty"`
+	UpgradesChecked bool
+	UpgradesError   string `json:",omitempty"`
+
+	// Chain is a shortest chain of requirements from the main module to the selected version
+	// (see Finding.Chain).
+	Chain []string `json:",omitempty"`
+}
+
+// Requirement is a requirement on a module version by another module version.
+type Requirement struct {
+	By       string // the requiring module, in path@version form (or just path for the main module)
+	Version  string // the required version
+	Selected bool   // Version is the version selected by MVS
+}
+
+// Why explains how the version of the module with the given path was selected for the build list,
+// using the build list, the module requirement graph, and the main module's 'go.mod'.
+// If upgrades is set, Why also reports any available update, which generally requires network access.
+// As with the rules that need the network, failing to check for updates is not an error:
+// the Explanation is still returned, with UpgradesError set.
+// If the module is not in the build list, Why returns a *UsageError.
+func Why(ctx context.Context, s *Snapshot, path string, upgrades bool) (*Explanation, error) {
+	mods, err := s.BuildList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{Path: path}
+	found := false
+	for _, mod := range mods {
+		if mod.Path == path {
+			found = true
+			e.Selected, e.Main, e.Indirect = mod.Version, mod.Main, mod.Indirect
+		}
+	}
+	if !found {
+		return nil, &UsageError{Err: fmt.Errorf("module %s is not in the build list", path)}
+	}
+
+	g, err := s.Graph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.Requirements = []Requirement{}
+	for _, n := range g.Lookup(path) {
+		for _, by := range g.Predecessors(n) {
+			e.Requirements = append(e.Requirements, Requirement{By: by.String(), Version: n.Version, Selected: n.Version == e.Selected})
+		}
+	}
+	sort.SliceStable(e.Requirements, func(i, j int) bool {
+		x, y := e.Requirements[i], e.Requirements[j]
+		if c := semver.Compare(x.Version, y.Version); c != 0 {
+			return c < 0
+		}
+		return x.By < y.By
+	})
+	if !e.Main {
+		for _, n := range g.ShortestPath(g.Main(), modgraph.Node{Path: path, Version: e.Selected}) {
+			e.Chain = append(e.Chain, n.String())
+		}
+	}
+
+	e.Replace, err = s.replacement(ctx, path, e.Selected)
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.MainModFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, x := range file.Exclude {
+		if x.Path == path {
+			e.Excludes = append(e.Excludes, x)
+		}
+	}
+
+	if upgrades && !e.Main {
+		mods, err := s.Upgrades(ctx)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err != nil:
+			s.logf("gomodvet: cannot check for updates: %v\n", err)
+			e.UpgradesError = err.Error()
+		default:
+			e.UpgradesChecked = true
+			for _, mod := range mods {
+				if mod.Path == path && mod.Update != nil {
+					e.Update = mod.Update.Version
+				}
+			}
+		}
+	}
+	return e, nil
+}
+
+// Reason summarizes why e.Selected was selected, such as
+// "v1.2.0 is the highest required version".
+func (e *Explanation) Reason() string {
+	if e.Main {
+		return "the main module is always selected"
+	}
+	var highest string
+	for _, r := range e.Requirements {
+		if semver.Compare(r.Version, highest) > 0 {
+			highest = r.Version
+		}
+	}
+	switch {
+	case highest == e.Selected:
+		return fmt.Sprintf("%s is the highest required version", e.Selected)
+	case e.excluded(highest):
+		return fmt.Sprintf("%s is the next version after the highest required version %s, which is excluded", e.Selected, highest)
+	case highest == "":
+		return fmt.Sprintf("%s is not required by any module in the requirement graph", e.Selected)
+	}
+	return fmt.Sprintf("%s differs from the highest required version %s", e.Selected, highest)
+}
+
+// excluded reports if version is excluded in the main module's 'go.mod'.
+func (e *Explanation) excluded(version string) bool {
+	for _, x := range e.Excludes {
+		if x.Version == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thepudds/gomodvet/trace"
+	"github.com/thepudds/gomodvet/vet"
+)
+
+// why implements 'gomodvet why [-json] <module>', explaining how the version of a module was selected.
+// Available updates are also reported, unless -offline is set or the upgrades rule is disabled,
+// and shown as unknown if checking for them fails.
+func why(args []string, tr *trace.Trace) int {
+	fs := flag.NewFlagSet("why", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", *flagJSON, "emit the explanation as a JSON object on stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gomodvet why [-json] <module path>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		// fs has already reported the error.
+		return ArgErr
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return ArgErr
+	}
+	ctx := context.Background()
+	if *flagTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *flagTimeout)
+		defer cancel()
+	}
+
+	s, status := moduleSnapshot(ctx, tr)
+	if status != Success {
+		return status
+	}
+	upgrades, status := ruleEnabled(ctx, s, vet.Lookup("upgrades"))
+	if status != Success {
+		return status
+	}
+	e, err := vet.Why(ctx, s, strings.TrimSpace(fs.Arg(0)), upgrades && !*flagOffline)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gomodvet:", err)
+		if _, ok := err.(*vet.UsageError); ok {
+			return ArgErr
+		}
+		return InternalErr
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(e); err != nil {
+			fmt.Fprintln(os.Stderr, "gomodvet:", err)
+			return InternalErr
+		}
+		return Success
+	}
+	printExplanation(e)
+	return Success
+}
+
+// printExplanation prints e in text form, such as:
+//
+//	example.com/c v0.2.0
+//	    selected: v0.2.0 is the highest required version
+//	    required:
+//	        v0.1.0  by example.com/a@v1.0.0
+//	      * v0.2.0  by example.com/b@v1.1.0
+//	    update: v0.3.0
+//	    required via: example.com/hello -> example.com/b@v1.1.0 -> example.com/c@v0.2.0
+func printExplanation(e *vet.Explanation) {
+	line := e.Path
+	switch {
+	case e.Main:
+		line += " (main module)"
+	case e.Indirect:
+		line += " " + e.Selected + " (indirect)"
+	default:
+		line += " " + e.Selected
+	}
+	fmt.Println(line)
+	fmt.Printf("    selected: %s\n", e.Reason())
+	if len(e.Requirements) > 0 {
+		width := 0
+		for _, r := range e.Requirements {
+			if len(r.Version) > width {
+				width = len(r.Version)
+			}
+		}
+		fmt.Println("    required:")
+		for _, r := range e.Requirements {
+			mark := " "
+			if r.Selected {
+				mark = "*"
+			}
+			fmt.Printf("      %s %-*s  by %s\n", mark, width, r.Version, r.By)
+		}
+	}
+	if e.Replace != nil {
+		fmt.Printf("    replaced: %s => %s at %s\n", e.Replace.Old, e.Replace.New, e.Replace.Pos)
+	}
+	for _, x := range e.Excludes {
+		fmt.Printf("    excluded: %s at %s\n", x.Version, x.Pos)
+	}
+	switch {
+	case e.UpgradesError != "":
+		fmt.Println("    update: unknown, checking for updates failed")
+	case e.Update != "":
+		fmt.Printf("    update: %s\n", e.Update)
+	case e.UpgradesChecked:
+		fmt.Println("    update: none, the selected version is the latest")
+	}
+	if len(e.Chain) > 0 {
+		fmt.Printf("    required via: %s\n", strings.Join(e.Chain, " -> "))
+	}
+}